@@ -0,0 +1,103 @@
+// Package cbor encodes and decodes structs of omit, null and omitnull values
+// and Go primitives as CBOR (RFC 8949) using only the standard library.
+//
+// CBOR distinguishes the undefined (0xf7) and null (0xf6) simple values which
+// map directly onto the states of the Val types:
+//
+//	State | Struct field     | Anywhere else
+//	------|------------------|--------------
+//	unset | key is omitted   | undefined
+//	null  | null             | null
+//	set   | the value        | the value
+//
+// When decoding, a missing key leaves a field untouched (so it stays unset in
+// a fresh struct), undefined makes an omit or omitnull value unset and null
+// makes a null or omitnull value null. Decoding null into an omit.Val is an
+// error, while undefined is decoded as null for null.Val as it cannot be
+// unset.
+//
+// Struct keys come from the `cbor` struct tag and fall back to the Go field
+// name. Fields tagged "-" are skipped.
+//
+// Other types are mapped as follows: bools, integers (major types 0 and 1),
+// floats, strings (major type 3), []byte and [N]byte (major type 2), slices
+// and arrays (major type 4), maps and structs (major type 5). Nil pointers,
+// slices and maps are encoded as null. Structs without any exported fields
+// cannot be encoded or decoded, except for time.Time which is encoded as an
+// RFC 3339 string with tag 0. Decoding time.Time also accepts an epoch based
+// number with tag 1. Other tags are ignored when decoding and the tagged item
+// is decoded in their place.
+//
+// Arrays, maps and tags may be nested at most MaxDepth levels deep when
+// decoding.
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TagName is the struct tag that map keys are read from.
+const TagName = "cbor"
+
+// MaxDepth is the maximum nesting depth of the items that Unmarshal accepts.
+const MaxDepth = 1000
+
+var (
+	// ErrSyntax is returned when the data is not well-formed CBOR.
+	ErrSyntax = errors.New("cbor: malformed data")
+	// ErrUnsupportedType is returned when a Go type cannot be encoded or
+	// decoded.
+	ErrUnsupportedType = errors.New("cbor: unsupported type")
+	// ErrTypeMismatch is returned when a CBOR item cannot be decoded into
+	// the Go value it was destined for.
+	ErrTypeMismatch = errors.New("cbor: type mismatch")
+	// ErrMaxDepth is returned when the data is nested deeper than MaxDepth.
+	ErrMaxDepth = errors.New("cbor: exceeded max depth")
+)
+
+// Tag numbers
+const (
+	tagDateTime uint64 = 0
+	tagEpoch    uint64 = 1
+)
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// Options control the encoding
+type Options struct {
+	// Deterministic sorts the keys of maps and structs by their encoded
+	// bytes and encodes floats in the shortest form that preserves their
+	// value, as described in RFC 8949 section 4.2. Integers and lengths are
+	// always encoded in their shortest form.
+	Deterministic bool
+}
+
+// Marshal encodes v as CBOR.
+func Marshal(v any, opts Options) ([]byte, error) {
+	e := &encoder{opts: opts}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes the single CBOR item in data into the value pointed to
+// by v.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: must decode into a non-nil pointer, got %T", ErrUnsupportedType, v)
+	}
+
+	d := &decoder{data: data}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.off != len(d.data) {
+		return fmt.Errorf("%w: trailing data after item", ErrSyntax)
+	}
+	return nil
+}
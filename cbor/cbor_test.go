@@ -0,0 +1,380 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type testStruct struct {
+	Omit     omit.Val[int]         `cbor:"o"`
+	Null     null.Val[string]      `cbor:"n"`
+	OmitNull omitnull.Val[float64] `cbor:"on"`
+	Plain    uint8                 `cbor:"p"`
+	Ptr      *int                  `cbor:"ptr"`
+	List     []omitnull.Val[int]   `cbor:"list"`
+	Ignored  int                   `cbor:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"nil", nil, "f6"},
+		{"true", true, "f5"},
+		{"false", false, "f4"},
+		{"uint small", 23, "17"},
+		{"uint8", 24, "1818"},
+		{"uint16", 1000, "1903e8"},
+		{"uint32", 1000000, "1a000f4240"},
+		{"uint64", uint64(1000000000000), "1b000000e8d4a51000"},
+		{"max uint64", uint64(math.MaxUint64), "1bffffffffffffffff"},
+		{"neg", -1, "20"},
+		{"neg uint8", -100, "3863"},
+		{"min int64", int64(math.MinInt64), "3b7fffffffffffffff"},
+		{"float32", float32(100000.0), "fa47c35000"},
+		{"float64", 1.1, "fb3ff199999999999a"},
+		{"string", "IETF", "6449455446"},
+		{"empty string", "", "60"},
+		{"bytes", []byte{1, 2, 3, 4}, "4401020304"},
+		{"byte array", [2]byte{1, 2}, "420102"},
+		{"nil bytes", []byte(nil), "f6"},
+		{"array", []int{1, 2, 3}, "83010203"},
+		{"nil ptr", (*int)(nil), "f6"},
+		{"unset omit", omit.Val[int]{}, "f7"},
+		{"set omit", omit.From(1), "01"},
+		{"null", null.Val[int]{}, "f6"},
+		{"unset omitnull", omitnull.Val[int]{}, "f7"},
+		{"null omitnull", omitnull.FromPtr[int](nil), "f6"},
+		{"val array", []omitnull.Val[int]{{}, omitnull.FromPtr[int](nil), omitnull.From(1)}, "83f7f601"},
+		{"struct unset", testStruct{Null: null.From("")}, "a4616e60617000 63707472f6 646c697374f6"},
+		{"struct set", testStruct{
+			Omit:     omit.From(1),
+			OmitNull: omitnull.FromPtr[float64](nil),
+			Plain:    2,
+		}, "a6616f01616ef6626f6ef6617002 63707472f6 646c697374f6"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := Marshal(test.in, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := mustHex(t, test.want); !bytes.Equal(b, want) {
+				t.Errorf("want: %x got: %x", want, b)
+			}
+		})
+	}
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"half zero", 0.0, "f90000"},
+		{"half neg zero", math.Copysign(0, -1), "f98000"},
+		{"half", 1.5, "f93e00"},
+		{"half max", 65504.0, "f97bff"},
+		{"half subnormal", 5.960464477539063e-8, "f90001"},
+		{"half inf", math.Inf(-1), "f9fc00"},
+		{"half nan", math.NaN(), "f97e00"},
+		{"single", 100000.0, "fa47c35000"},
+		{"double", 1.1, "fb3ff199999999999a"},
+		{"map keys", map[string]int{"bb": 1, "a": 2, "c": 3}, "a3616102616303626262 01"},
+		{"struct keys", testStruct{Omit: omit.From(1), Null: null.From("x"), List: []omitnull.Val[int]{}},
+			"a5616e6178616f01617000 63707472f6 646c69737480"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := Marshal(test.in, Options{Deterministic: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := mustHex(t, test.want); !bytes.Equal(b, want) {
+				t.Errorf("want: %x got: %x", want, b)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	one := 1
+	in := testStruct{
+		Omit:     omit.From(5),
+		Null:     null.From(""),
+		OmitNull: omitnull.From(1.5),
+		Plain:    255,
+		Ptr:      &one,
+		List:     []omitnull.Val[int]{{}, omitnull.FromPtr[int](nil), omitnull.From(0)},
+		Ignored:  5,
+	}
+
+	for _, opts := range []Options{{}, {Deterministic: true}} {
+		b, err := Marshal(in, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out testStruct
+		if err := Unmarshal(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		want := in
+		want.Ignored = 0
+		if !reflect.DeepEqual(want, out) {
+			t.Errorf("want: %#v\ngot:  %#v", want, out)
+		}
+	}
+}
+
+func TestUnmarshalStates(t *testing.T) {
+	t.Parallel()
+
+	out := testStruct{
+		Omit:     omit.From(1),
+		Null:     null.From("stale"),
+		OmitNull: omitnull.From(1.0),
+	}
+	// {"n": undefined, "on": null, "x": [1, {"a": h'00'}]}
+	if err := Unmarshal(mustHex(t, "a3616ef7626f6ef661788201a1616141 00"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Omit.MustGet() != 1 {
+		t.Error("missing key should leave the field alone")
+	}
+	if !out.Null.IsNull() {
+		t.Error("undefined should make null.Val null")
+	}
+	if !out.OmitNull.IsNull() {
+		t.Error("null should make omitnull.Val null")
+	}
+
+	out = testStruct{OmitNull: omitnull.From(1.0)}
+	if err := Unmarshal(mustHex(t, "a1626f6ef7"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.OmitNull.IsUnset() {
+		t.Error("undefined should make omitnull.Val unset")
+	}
+
+	err := Unmarshal(mustHex(t, "a1616ff6"), &out)
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Error("expected a type mismatch for null into omit, got:", err)
+	}
+
+	var o omit.Val[int]
+	if err := Unmarshal(mustHex(t, "f7"), &o); err != nil {
+		t.Fatal(err)
+	}
+	if !o.IsUnset() {
+		t.Error("should be unset")
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		out  any
+		want any
+	}{
+		{"uint", "1903e8", new(uint16), uint16(1000)},
+		{"neg", "3863", new(int8), int8(-100)},
+		{"min int64", "3b7fffffffffffffff", new(int64), int64(math.MinInt64)},
+		{"int into float", "20", new(float64), -1.0},
+		{"half", "f93e00", new(float32), float32(1.5)},
+		{"single", "fa47c35000", new(float64), 100000.0},
+		{"tagged", "c11a514b67b0", new(int64), int64(1363896240)},
+		{"indefinite bytes", "5f42010243030405ff", new([]byte), []byte{1, 2, 3, 4, 5}},
+		{"indefinite text", "7f657374726561646d696e67ff", new(string), "streaming"},
+		{"indefinite array", "9f018202039f0405ffff", new(any), []any{uint64(1), []any{uint64(2), uint64(3)}, []any{uint64(4), uint64(5)}}},
+		{"indefinite map", "bf61610161629f0203ffff", new(map[string]any), map[string]any{"a": uint64(1), "b": []any{uint64(2), uint64(3)}}},
+		{"any", "a26161016162820203", new(any), map[string]any{"a": uint64(1), "b": []any{uint64(2), uint64(3)}}},
+		{"array", "83010203", new([2]int), [2]int{1, 2}},
+		{"ptr", "01", new(*int), func() *int { i := 1; return &i }()},
+		{"null ptr", "f6", new(*int), (*int)(nil)},
+		{"null into int", "f6", new(int), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Unmarshal(mustHex(t, test.in), test.out); err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(test.out).Elem().Interface(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("want: %#v got: %#v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		out  any
+		err  error
+	}{
+		{"empty", "", new(int), ErrSyntax},
+		{"truncated", "1903", new(int), ErrSyntax},
+		{"reserved", "1c", new(int), ErrSyntax},
+		{"trailing", "0101", new(int), ErrSyntax},
+		{"long array", "9b00000000ffffffff", new([]int), ErrSyntax},
+		{"bad utf8", "61ff", new(string), ErrSyntax},
+		{"bad chunk", "5f6161ff", new([]byte), ErrSyntax},
+		{"overflow", "190100", new(uint8), ErrTypeMismatch},
+		{"negative uint", "20", new(uint), ErrTypeMismatch},
+		{"neg overflow", "3bffffffffffffffff", new(int64), ErrTypeMismatch},
+		{"mismatch", "6161", new(int), ErrTypeMismatch},
+		{"byte array length", "420102", new([3]byte), ErrTypeMismatch},
+		{"not a pointer", "01", 0, ErrUnsupportedType},
+		{"opaque struct", "a0", new(struct{ x int }), ErrUnsupportedType},
+		{"time tag", "c26161", new(time.Time), ErrTypeMismatch},
+		{"time text", "6161", new(time.Time), ErrTypeMismatch},
+		{"deep array", strings.Repeat("81", MaxDepth+1) + "01", new(any), ErrMaxDepth},
+		{"deep tag", strings.Repeat("c6", MaxDepth+1) + "01", new(int), ErrMaxDepth},
+		{"deep skip", "a16178" + strings.Repeat("81", MaxDepth+1) + "01", new(testStruct), ErrMaxDepth},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Unmarshal(mustHex(t, test.in), test.out)
+			if !errors.Is(err, test.err) {
+				t.Errorf("want: %v got: %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestHalf(t *testing.T) {
+	t.Parallel()
+
+	for h := range uint16(math.MaxUint16) {
+		f := fromHalf(h)
+		if math.IsNaN(f) {
+			continue
+		}
+		got, ok := toHalf(f)
+		if !ok || got != h {
+			t.Fatalf("%04x -> %g -> %04x, %t", h, f, got, ok)
+		}
+	}
+
+	if _, ok := toHalf(65520); ok {
+		t.Error("65520 should not fit in a half")
+	}
+	if _, ok := toHalf(1.1); ok {
+		t.Error("1.1 should not fit in a half")
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	t.Parallel()
+
+	type Base struct {
+		ID   omit.Val[int] `cbor:"id"`
+		Name string        `cbor:"name"`
+	}
+	type embedded struct {
+		*Base
+		Name string `cbor:"name"`
+	}
+
+	b, err := Marshal(embedded{Name: "a"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b); got != "a1646e616d656161" {
+		t.Errorf("wrong encoding: %s", got)
+	}
+
+	b, err = Marshal(embedded{Base: &Base{ID: omit.From(1), Name: "shadowed"}, Name: "a"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b); got != "a262696401646e616d656161" {
+		t.Errorf("wrong encoding: %s", got)
+	}
+
+	var out embedded
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Base == nil || out.ID.GetOrZero() != 1 || out.Name != "a" || out.Base.Name != "" {
+		t.Errorf("wrong value: %#v", out)
+	}
+}
+
+func TestTime(t *testing.T) {
+	t.Parallel()
+
+	when := time.Date(2013, 3, 21, 20, 4, 0, 500, time.FixedZone("", -5*3600))
+	b, err := Marshal(omitnull.From(when), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b); got != "c07821323031332d30332d32315432303a30343a30302e303030303030352d30353a3030" {
+		t.Errorf("wrong encoding: %s", got)
+	}
+
+	var got omitnull.Val[time.Time]
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.MustGet().Equal(when) {
+		t.Errorf("want: %v got: %v", when, got.MustGet())
+	}
+
+	var epoch time.Time
+	if err := Unmarshal(mustHex(t, "c1fb41d452d9ec200000"), &epoch); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1363896240, 5e8); !epoch.Equal(want) {
+		t.Errorf("want: %v got: %v", want, epoch)
+	}
+
+	if _, err := Marshal(struct{ x int }{}, Options{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("opaque struct should not encode: %v", err)
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	clean := make([]byte, 0, len(s))
+	for i := range len(s) {
+		if s[i] != ' ' {
+			clean = append(clean, s[i])
+		}
+	}
+	b, err := hex.DecodeString(string(clean))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,655 @@
+package cbor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// indefinite is the length returned for indefinite-length items
+const indefinite = -1
+
+type decoder struct {
+	data  []byte
+	off   int
+	depth int
+}
+
+// nest is called before descending into an array, map or tag, the returned
+// function must be called once the item is done.
+func (d *decoder) nest() (func(), error) {
+	if d.depth >= MaxDepth {
+		return nil, fmt.Errorf("%w of %d", ErrMaxDepth, MaxDepth)
+	}
+	d.depth++
+	return func() { d.depth-- }, nil
+}
+
+func (d *decoder) peek() (byte, error) {
+	if d.off >= len(d.data) {
+		return 0, fmt.Errorf("%w: unexpected end of data", ErrSyntax)
+	}
+	return d.data[d.off], nil
+}
+
+func (d *decoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrSyntax)
+	}
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// head reads an initial byte and its argument. For the major types that can
+// have an indefinite length info is 31 and the argument is 0.
+func (d *decoder) head() (major, info byte, arg uint64, err error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		b, err = d.read(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		switch len(b) {
+		case 1:
+			arg = uint64(b[0])
+		case 2:
+			arg = uint64(binary.BigEndian.Uint16(b))
+		case 4:
+			arg = uint64(binary.BigEndian.Uint32(b))
+		default:
+			arg = binary.BigEndian.Uint64(b)
+		}
+		return major, info, arg, nil
+	case info == 31 && major >= majorBytes && major <= majorMap:
+		return major, info, 0, nil
+	case info == 31 && major == majorSimple:
+		return 0, 0, 0, fmt.Errorf("%w: unexpected break", ErrSyntax)
+	default:
+		return 0, 0, 0, fmt.Errorf("%w: reserved additional information %d", ErrSyntax, info)
+	}
+}
+
+// length converts the argument of an array or map head to a length, each
+// item takes at least one byte so lengths longer than the remaining data are
+// rejected before anything is allocated for them.
+func (d *decoder) length(info byte, arg uint64) (int, error) {
+	if info == 31 {
+		return indefinite, nil
+	}
+	if arg > uint64(len(d.data)-d.off) {
+		return 0, fmt.Errorf("%w: length %d exceeds data", ErrSyntax, arg)
+	}
+	return int(arg), nil
+}
+
+// more reports whether another item follows in an array or map of length n
+// after i items, consuming the break code that ends indefinite ones.
+func (d *decoder) more(n, i int) (bool, error) {
+	if n != indefinite {
+		return i < n, nil
+	}
+	b, err := d.peek()
+	if err != nil {
+		return false, err
+	}
+	if b == breakCode {
+		d.off++
+		return false, nil
+	}
+	return true, nil
+}
+
+// str reads the contents of a byte or text string, concatenating the chunks
+// of an indefinite-length one.
+func (d *decoder) str(major, info byte, arg uint64) ([]byte, error) {
+	if info != 31 {
+		return d.read(arg)
+	}
+
+	var out []byte
+	for {
+		b, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if b == breakCode {
+			d.off++
+			break
+		}
+		chunkMajor, chunkInfo, chunkArg, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkInfo == 31 {
+			return nil, fmt.Errorf("%w: invalid chunk in indefinite-length string", ErrSyntax)
+		}
+		chunk, err := d.read(chunkArg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+	}
+	if out == nil {
+		out = []byte{}
+	}
+	return out, nil
+}
+
+func (d *decoder) decode(v reflect.Value) error {
+	b, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	if kind := optreflect.KindOf(v.Type()); kind != optreflect.KindNone {
+		return d.decodeVal(v, kind, b)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if b == simpleNull || b == simpleUndefined {
+			d.off++
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		x, err := d.value()
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return d.time(v)
+		}
+		if optreflect.Opaque(v.Type(), TagName) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+	}
+
+	major, info, arg, err := d.head()
+	if err != nil {
+		return err
+	}
+
+	if major == majorArray || major == majorMap || major == majorTag {
+		done, err := d.nest()
+		if err != nil {
+			return err
+		}
+		defer done()
+	}
+
+	switch major {
+	case majorUint, majorNegInt:
+		return setInt(v, major, arg)
+	case majorBytes:
+		raw, err := d.str(major, info, arg)
+		if err != nil {
+			return err
+		}
+		return setBytes(v, raw)
+	case majorText:
+		text, err := d.str(major, info, arg)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(text) {
+			return fmt.Errorf("%w: invalid UTF-8 in text string", ErrSyntax)
+		}
+		if v.Kind() != reflect.String {
+			return mismatch("text string", v)
+		}
+		v.SetString(string(text))
+		return nil
+	case majorArray:
+		n, err := d.length(info, arg)
+		if err != nil {
+			return err
+		}
+		return d.array(v, n)
+	case majorMap:
+		n, err := d.length(info, arg)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			return d.structure(v, n)
+		case reflect.Map:
+			return d.mapping(v, n)
+		}
+		return mismatch("map", v)
+	case majorTag:
+		return d.decode(v)
+	}
+
+	return d.simple(v, info, arg)
+}
+
+// time decodes an RFC 3339 string or a number of seconds since the epoch,
+// with or without their tag, into the time.Time v.
+func (d *decoder) time(v reflect.Value) error {
+	b, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if b>>5 == majorTag {
+		_, _, tag, err := d.head()
+		if err != nil {
+			return err
+		}
+		if tag != tagDateTime && tag != tagEpoch {
+			return fmt.Errorf("%w: cannot decode tag %d into %s", ErrTypeMismatch, tag, v.Type())
+		}
+	}
+
+	x, err := d.value()
+	if err != nil {
+		return err
+	}
+
+	var t time.Time
+	switch x := x.(type) {
+	case string:
+		if t, err = time.Parse(time.RFC3339Nano, x); err != nil {
+			return fmt.Errorf("%w: %w", ErrTypeMismatch, err)
+		}
+	case uint64:
+		if x > math.MaxInt64 {
+			return fmt.Errorf("%w: %d overflows %s", ErrTypeMismatch, x, v.Type())
+		}
+		t = time.Unix(int64(x), 0)
+	case int64:
+		t = time.Unix(x, 0)
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return fmt.Errorf("%w: %g is not a valid time", ErrTypeMismatch, x)
+		}
+		sec, frac := math.Modf(x)
+		t = time.Unix(int64(sec), int64(frac*1e9))
+	case nil:
+		return nil
+	default:
+		return mismatch(fmt.Sprintf("%T", x), v)
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// decodeVal decodes into one of the Val types, b is the initial byte of the
+// item.
+func (d *decoder) decodeVal(v reflect.Value, kind optreflect.Kind, b byte) error {
+	switch {
+	case b == simpleUndefined && kind.CanUnset():
+		d.off++
+		optreflect.SetUnset(v)
+		return nil
+	case b == simpleNull || b == simpleUndefined:
+		if !kind.CanNull() {
+			return fmt.Errorf("%w: cannot decode null into %s", ErrTypeMismatch, v.Type())
+		}
+		d.off++
+		optreflect.SetNull(v)
+		return nil
+	}
+
+	elem := reflect.New(optreflect.ElemType(v.Type())).Elem()
+	if err := d.decode(elem); err != nil {
+		return err
+	}
+	optreflect.Set(v, elem)
+	return nil
+}
+
+func (d *decoder) array(v reflect.Value, n int) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		v.SetLen(0)
+		for i := 0; ; i++ {
+			if ok, err := d.more(n, i); err != nil {
+				return err
+			} else if !ok {
+				break
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decode(elem); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+		return nil
+	case reflect.Array:
+		i := 0
+		for ; ; i++ {
+			if ok, err := d.more(n, i); err != nil {
+				return err
+			} else if !ok {
+				break
+			}
+			if i >= v.Len() {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		for ; i < v.Len(); i++ {
+			v.Index(i).SetZero()
+		}
+		return nil
+	}
+
+	return mismatch("array", v)
+}
+
+// structure decodes a map into the fields of a struct by their key, keys that
+// do not match a field are skipped.
+func (d *decoder) structure(v reflect.Value, n int) error {
+	fields := optreflect.Fields(v.Type(), TagName)
+	for i := 0; ; i++ {
+		if ok, err := d.more(n, i); err != nil {
+			return err
+		} else if !ok {
+			break
+		}
+
+		var key string
+		if err := d.decode(reflect.ValueOf(&key).Elem()); err != nil {
+			return err
+		}
+
+		f, ok := optreflect.FieldByName(fields, key)
+		if !ok {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(f.Alloc(v)); err != nil {
+			return fmt.Errorf("%w (field %s)", err, f.Name)
+		}
+	}
+	return nil
+}
+
+func (d *decoder) mapping(v reflect.Value, n int) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	for i := 0; ; i++ {
+		if ok, err := d.more(n, i); err != nil {
+			return err
+		} else if !ok {
+			break
+		}
+
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := d.decode(key); err != nil {
+			return err
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decode(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+// simple decodes major type 7, null and undefined leave v untouched like
+// encoding/json does for null.
+func (d *decoder) simple(v reflect.Value, info byte, arg uint64) error {
+	switch info {
+	case 20, 21:
+		if v.Kind() != reflect.Bool {
+			return mismatch("bool", v)
+		}
+		v.SetBool(info == 21)
+		return nil
+	case 22, 23:
+		return nil
+	case 25, 26, 27:
+		var f float64
+		switch info {
+		case 25:
+			f = fromHalf(uint16(arg))
+		case 26:
+			f = float64(math.Float32frombits(uint32(arg)))
+		default:
+			f = math.Float64frombits(arg)
+		}
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			return mismatch("float", v)
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("%w: %g overflows %s", ErrTypeMismatch, f, v.Type())
+		}
+		v.SetFloat(f)
+		return nil
+	}
+
+	return fmt.Errorf("%w: simple value %d", ErrUnsupportedType, arg)
+}
+
+func setInt(v reflect.Value, major byte, arg uint64) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if arg > math.MaxInt64 {
+			return overflow(major, arg, v)
+		}
+		n := int64(arg)
+		if major == majorNegInt {
+			n = -1 - n
+		}
+		if v.OverflowInt(n) {
+			return overflow(major, arg, v)
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if major == majorNegInt || v.OverflowUint(arg) {
+			return overflow(major, arg, v)
+		}
+		v.SetUint(arg)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f := float64(arg)
+		if major == majorNegInt {
+			f = -1 - f
+		}
+		v.SetFloat(f)
+		return nil
+	}
+
+	return mismatch("integer", v)
+}
+
+func setBytes(v reflect.Value, b []byte) error {
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(append([]byte{}, b...))
+		return nil
+	case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+		if len(b) != v.Len() {
+			return fmt.Errorf("%w: %d bytes into %s", ErrTypeMismatch, len(b), v.Type())
+		}
+		for i, c := range b {
+			v.Index(i).SetUint(uint64(c))
+		}
+		return nil
+	}
+
+	return mismatch("byte string", v)
+}
+
+// value decodes the next item into the natural Go type for it: uint64 or
+// int64, float64, bool, string, []byte, []any, map[string]any or nil.
+func (d *decoder) value() (any, error) {
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	if major == majorArray || major == majorMap || major == majorTag {
+		done, err := d.nest()
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+	}
+
+	switch major {
+	case majorUint:
+		return arg, nil
+	case majorNegInt:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: -1-%d overflows int64", ErrTypeMismatch, arg)
+		}
+		return -1 - int64(arg), nil
+	case majorBytes, majorText:
+		b, err := d.str(major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorBytes {
+			return append([]byte{}, b...), nil
+		}
+		if !utf8.Valid(b) {
+			return nil, fmt.Errorf("%w: invalid UTF-8 in text string", ErrSyntax)
+		}
+		return string(b), nil
+	case majorArray:
+		n, err := d.length(info, arg)
+		if err != nil {
+			return nil, err
+		}
+		out := []any{}
+		for i := 0; ; i++ {
+			if ok, err := d.more(n, i); err != nil {
+				return nil, err
+			} else if !ok {
+				break
+			}
+			x, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, x)
+		}
+		return out, nil
+	case majorMap:
+		n, err := d.length(info, arg)
+		if err != nil {
+			return nil, err
+		}
+		out := map[string]any{}
+		if err := d.mapping(reflect.ValueOf(out), n); err != nil {
+			return nil, err
+		}
+		return out, nil
+	case majorTag:
+		return d.value()
+	}
+
+	switch info {
+	case 20, 21:
+		return info == 21, nil
+	case 22, 23:
+		return nil, nil
+	}
+	var f float64
+	if err := d.simple(reflect.ValueOf(&f).Elem(), info, arg); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// skip consumes the next item without decoding it.
+func (d *decoder) skip() error {
+	major, info, arg, err := d.head()
+	if err != nil {
+		return err
+	}
+
+	if major == majorArray || major == majorMap || major == majorTag {
+		done, err := d.nest()
+		if err != nil {
+			return err
+		}
+		defer done()
+	}
+
+	switch major {
+	case majorBytes, majorText:
+		_, err = d.str(major, info, arg)
+		return err
+	case majorArray, majorMap:
+		n, err := d.length(info, arg)
+		if err != nil {
+			return err
+		}
+		per := 1
+		if major == majorMap {
+			per = 2
+		}
+		for i := 0; ; i++ {
+			if ok, err := d.more(n, i); err != nil {
+				return err
+			} else if !ok {
+				return nil
+			}
+			for range per {
+				if err := d.skip(); err != nil {
+					return err
+				}
+			}
+		}
+	case majorTag:
+		return d.skip()
+	}
+	return nil
+}
+
+func mismatch(item string, v reflect.Value) error {
+	return fmt.Errorf("%w: cannot decode %s into %s", ErrTypeMismatch, item, v.Type())
+}
+
+func overflow(major byte, arg uint64, v reflect.Value) error {
+	if major == majorNegInt {
+		return fmt.Errorf("%w: -1-%d overflows %s", ErrTypeMismatch, arg, v.Type())
+	}
+	return fmt.Errorf("%w: %d overflows %s", ErrTypeMismatch, arg, v.Type())
+}
@@ -0,0 +1,312 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// Major types
+const (
+	majorUint   byte = 0
+	majorNegInt byte = 1
+	majorBytes  byte = 2
+	majorText   byte = 3
+	majorArray  byte = 4
+	majorMap    byte = 5
+	majorTag    byte = 6
+	majorSimple byte = 7
+)
+
+// Initial bytes of the simple values and floats
+const (
+	simpleFalse     byte = 0xf4
+	simpleTrue      byte = 0xf5
+	simpleNull      byte = 0xf6
+	simpleUndefined byte = 0xf7
+	floatHalf       byte = 0xf9
+	floatSingle     byte = 0xfa
+	floatDouble     byte = 0xfb
+	breakCode       byte = 0xff
+)
+
+type encoder struct {
+	buf  []byte
+	opts Options
+}
+
+// head appends the initial byte for major with the argument n in its
+// shortest form.
+func (e *encoder) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, major|26), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, major|27), n)
+	}
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, simpleNull)
+		return nil
+	}
+
+	if optreflect.KindOf(v.Type()) != optreflect.KindNone {
+		state, val := optreflect.Get(v)
+		switch state {
+		case optreflect.StateUnset:
+			e.buf = append(e.buf, simpleUndefined)
+		case optreflect.StateNull:
+			e.buf = append(e.buf, simpleNull)
+		default:
+			return e.encode(val)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, simpleTrue)
+		} else {
+			e.buf = append(e.buf, simpleFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n < 0 {
+			e.head(majorNegInt, uint64(-1-n))
+		} else {
+			e.head(majorUint, uint64(n))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.head(majorUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.float(v.Float(), v.Kind() == reflect.Float32)
+	case reflect.String:
+		e.head(majorText, uint64(v.Len()))
+		e.buf = append(e.buf, v.String()...)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, simpleNull)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.head(majorBytes, uint64(v.Len()))
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		return e.array(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.head(majorBytes, uint64(v.Len()))
+			for i := range v.Len() {
+				e.buf = append(e.buf, byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+		return e.array(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, simpleNull)
+			return nil
+		}
+		return e.mapping(v)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			text := v.Interface().(time.Time).Format(time.RFC3339Nano)
+			e.head(majorTag, tagDateTime)
+			e.head(majorText, uint64(len(text)))
+			e.buf = append(e.buf, text...)
+			return nil
+		}
+		if optreflect.Opaque(v.Type(), TagName) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		return e.structure(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, simpleNull)
+			return nil
+		}
+		return e.encode(v.Elem())
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+
+	return nil
+}
+
+func (e *encoder) array(v reflect.Value) error {
+	e.head(majorArray, uint64(v.Len()))
+	for i := range v.Len() {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry is an encoded key and value of a map
+type entry struct {
+	key, value []byte
+}
+
+func (e *encoder) mapping(v reflect.Value) error {
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := e.sub(iter.Key())
+		if err != nil {
+			return err
+		}
+		value, err := e.sub(iter.Value())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: key, value: value})
+	}
+	e.entries(entries)
+	return nil
+}
+
+// structure encodes the fields of a struct as a map, leaving out Val fields
+// that are unset.
+func (e *encoder) structure(v reflect.Value) error {
+	var entries []entry
+	for _, f := range optreflect.Fields(v.Type(), TagName) {
+		field, ok := f.Value(v)
+		if !ok || optreflect.KindOf(f.Type) != optreflect.KindNone && optreflect.StateOf(field) == optreflect.StateUnset {
+			continue
+		}
+
+		key, err := e.sub(reflect.ValueOf(f.Name))
+		if err != nil {
+			return err
+		}
+		value, err := e.sub(field)
+		if err != nil {
+			return fmt.Errorf("%w (field %s)", err, f.Name)
+		}
+		entries = append(entries, entry{key: key, value: value})
+	}
+	e.entries(entries)
+	return nil
+}
+
+// sub encodes v on its own so it can be sorted before being written.
+func (e *encoder) sub(v reflect.Value) ([]byte, error) {
+	sub := &encoder{opts: e.opts}
+	if err := sub.encode(v); err != nil {
+		return nil, err
+	}
+	return sub.buf, nil
+}
+
+func (e *encoder) entries(entries []entry) {
+	if e.opts.Deterministic {
+		slices.SortFunc(entries, func(a, b entry) int {
+			return bytes.Compare(a.key, b.key)
+		})
+	}
+
+	e.head(majorMap, uint64(len(entries)))
+	for _, ent := range entries {
+		e.buf = append(e.buf, ent.key...)
+		e.buf = append(e.buf, ent.value...)
+	}
+}
+
+// float encodes f as a single if it came from a float32 and a double
+// otherwise, unless the encoding is deterministic in which case the shortest
+// encoding that preserves the value is used.
+func (e *encoder) float(f float64, single bool) {
+	if e.opts.Deterministic {
+		if math.IsNaN(f) {
+			e.buf = append(e.buf, floatHalf, 0x7e, 0x00)
+			return
+		}
+		if h, ok := toHalf(f); ok {
+			e.buf = binary.BigEndian.AppendUint16(append(e.buf, floatHalf), h)
+			return
+		}
+		single = float64(float32(f)) == f
+	}
+
+	if single {
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, floatSingle), math.Float32bits(float32(f)))
+	} else {
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, floatDouble), math.Float64bits(f))
+	}
+}
+
+// toHalf returns the IEEE 754 half precision bits for f if it can be
+// represented exactly.
+func toHalf(f float64) (uint16, bool) {
+	var sign uint16
+	if math.Signbit(f) {
+		sign = 0x8000
+		f = -f
+	}
+
+	switch {
+	case f == 0:
+		return sign, true
+	case math.IsInf(f, 0):
+		return sign | 0x7c00, true
+	case f < 0x1p-14:
+		// subnormal: f = mant * 2^-24
+		mant := math.Ldexp(f, 24)
+		if mant != math.Trunc(mant) {
+			return 0, false
+		}
+		return sign | uint16(mant), true
+	}
+
+	frac, exp := math.Frexp(f)
+	exp-- // f = (2*frac) * 2^exp where 2*frac is in [1, 2)
+	if exp > 15 {
+		return 0, false
+	}
+	mant := (2*frac - 1) * 1024
+	if mant != math.Trunc(mant) {
+		return 0, false
+	}
+	return sign | uint16(exp+15)<<10 | uint16(mant), true
+}
+
+// fromHalf converts IEEE 754 half precision bits to a float64.
+func fromHalf(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+		f = math.Inf(1)
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
@@ -47,6 +47,12 @@ import (
 // JSONMarshal and JSONUnmarshal are used to marshal and unmarshal
 // values to and from JSON. They can be overridden to use a custom
 // JSON library or to add custom marshaling behavior.
+//
+// When encoding/json/v2 is available (Go 1.27, or GOEXPERIMENT=jsonv2 on Go
+// 1.25 and 1.26) the Val types also implement its MarshalJSONTo and
+// UnmarshalJSONFrom methods. encoding/json prefers those over MarshalJSON and
+// UnmarshalJSON in that case, so the wrapped value of a Val is encoded and
+// decoded by encoding/json/v2 and these hooks are not called for it.
 var (
 	JSONMarshal   = json.Marshal
 	JSONUnmarshal = json.Unmarshal
@@ -56,7 +62,8 @@ var errNilPtr = errors.New("destination pointer is nil") // embedded in descript
 
 // ConvertAssign copies to dest the value in src, converting it if possible.
 // An error is returned if the copy would result in loss of information.
-// dest should be a pointer type.
+// dest should be a pointer type. Postgres array literals can be stored in
+// slices other than []byte.
 func ConvertAssign(dest, src any) error {
 	// Common cases, without reflect.
 	switch s := src.(type) {
@@ -157,6 +164,23 @@ func ConvertAssign(dest, src any) error {
 		}
 	}
 
+	// Sources such as sql.NullString, sql.Null[T] and the Val types are
+	// copied as is when dest has the same type, otherwise they are unwrapped
+	// to their driver.Value.
+	if vr, ok := src.(driver.Valuer); ok {
+		if dpv := reflect.ValueOf(dest); dpv.Kind() == reflect.Pointer && !dpv.IsNil() &&
+			reflect.TypeOf(src).AssignableTo(dpv.Type().Elem()) {
+			dpv.Elem().Set(reflect.ValueOf(src))
+			return nil
+		}
+
+		value, err := ToDriverValue(vr)
+		if err != nil {
+			return err
+		}
+		return ConvertAssign(dest, value)
+	}
+
 	var sv reflect.Value
 
 	switch d := dest.(type) {
@@ -182,6 +206,13 @@ func ConvertAssign(dest, src any) error {
 			*d = sql.RawBytes(b)
 			return nil
 		}
+	case *time.Time:
+		if d == nil {
+			return errNilPtr
+		}
+		if ok, err := convertTime(d, src); ok {
+			return err
+		}
 	case *bool:
 		bv, err := driver.Bool.ConvertValue(src)
 		if err == nil {
@@ -286,6 +317,11 @@ func ConvertAssign(dest, src any) error {
 			dv.SetString(string(v))
 			return nil
 		}
+	case reflect.Slice:
+		// Postgres arrays, []byte is handled above
+		if text, ok := asPGArrayText(src); ok && dv.Type().Elem().Kind() != reflect.Uint8 {
+			return scanPGArray(dv, text)
+		}
 	}
 
 	return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type %T", src, dest)
@@ -438,3 +438,455 @@ func TestRawBytesAllocs(t *testing.T) {
 		t.Fatalf("allocs = %v; want max 1", n)
 	}
 }
+
+func TestConvertAssignSQLNull(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("NullString", func(t *testing.T) {
+		valid := sql.NullString{String: "a", Valid: true}
+		want := "a"
+
+		var got string
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.NullString{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.NullString{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.NullString
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("NullInt64", func(t *testing.T) {
+		valid := sql.NullInt64{Int64: -5, Valid: true}
+		want := int64(-5)
+
+		var got int64
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.NullInt64{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.NullInt64{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.NullInt64
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("NullInt32", func(t *testing.T) {
+		valid := sql.NullInt32{Int32: 5, Valid: true}
+		want := int32(5)
+
+		var got int32
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.NullInt32{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.NullInt32{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.NullInt32
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("NullInt16", func(t *testing.T) {
+		valid := sql.NullInt16{Int16: 5, Valid: true}
+		want := int16(5)
+
+		var got int16
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.NullInt16{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.NullInt16{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.NullInt16
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("NullByte", func(t *testing.T) {
+		valid := sql.NullByte{Byte: 5, Valid: true}
+		want := byte(5)
+
+		var got byte
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.NullByte{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.NullByte{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.NullByte
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("NullFloat64", func(t *testing.T) {
+		valid := sql.NullFloat64{Float64: 1.5, Valid: true}
+		want := 1.5
+
+		var got float64
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.NullFloat64{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.NullFloat64{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.NullFloat64
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("NullBool", func(t *testing.T) {
+		valid := sql.NullBool{Bool: true, Valid: true}
+		want := true
+
+		var got bool
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.NullBool{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.NullBool{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.NullBool
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("NullTime", func(t *testing.T) {
+		valid := sql.NullTime{Time: now, Valid: true}
+		want := now
+
+		var got time.Time
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.NullTime{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.NullTime{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.NullTime
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("Null[string]", func(t *testing.T) {
+		valid := sql.Null[string]{V: "a", Valid: true}
+		want := "a"
+
+		var got string
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.Null[string]{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.Null[string]{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.Null[string]
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("Null[int]", func(t *testing.T) {
+		valid := sql.Null[int]{V: 5, Valid: true}
+		want := 5
+
+		var got int
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.Null[int]{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.Null[int]{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.Null[int]
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+	t.Run("Null[time.Time]", func(t *testing.T) {
+		valid := sql.Null[time.Time]{V: now, Valid: true}
+		want := now
+
+		var got time.Time
+		if err := ConvertAssign(&got, valid); err != nil {
+			t.Error(err)
+		} else if got != want {
+			t.Error("value wrong:", got)
+		}
+		if err := ConvertAssign(&got, sql.Null[time.Time]{}); err == nil {
+			t.Error("expected an error converting an invalid value to a plain value")
+		}
+
+		ptr := &want
+		if err := ConvertAssign(&ptr, sql.Null[time.Time]{}); err != nil {
+			t.Error(err)
+		} else if ptr != nil {
+			t.Error("expected an invalid value to make a pointer nil")
+		}
+
+		var n sql.Null[time.Time]
+		if err := ConvertAssign(&n, want); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+		if err := ConvertAssign(&n, nil); err != nil {
+			t.Error(err)
+		} else if n.Valid {
+			t.Error("expected invalid")
+		}
+		if err := ConvertAssign(&n, valid); err != nil {
+			t.Error(err)
+		} else if n != valid {
+			t.Error("value wrong:", n)
+		}
+	})
+}
+
+func TestConvertAssignSQLNullMixed(t *testing.T) {
+	t.Parallel()
+
+	var n sql.NullInt32
+	if err := ConvertAssign(&n, sql.NullInt64{Int64: 5, Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n != (sql.NullInt32{Int32: 5, Valid: true}) {
+		t.Error("wrong value:", n)
+	}
+
+	var s sql.Null[string]
+	if err := ConvertAssign(&s, sql.NullInt64{Int64: 5, Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	if s != (sql.Null[string]{V: "5", Valid: true}) {
+		t.Error("wrong value:", s)
+	}
+
+	if err := ConvertAssign(&s, sql.NullTime{}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Valid {
+		t.Error("expected invalid")
+	}
+}
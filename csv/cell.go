@@ -0,0 +1,88 @@
+package csv
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/optreflect"
+	"github.com/aarondl/opt/internal/textfmt"
+)
+
+type textWithMarshaler interface {
+	MarshalTextWith(opts opt.TextOptions) ([]byte, error)
+}
+
+type textWithUnmarshaler interface {
+	UnmarshalTextWith(text []byte, opts opt.TextOptions) error
+}
+
+// errNullPlain is returned when the null marker is read into a field that
+// cannot be null.
+var errNullPlain = errors.New("cannot unmarshal null into a value that is not nullable")
+
+// encodeCell returns the text for the field v. Vals encode themselves, nil
+// pointers are null and everything else is a set value.
+func encodeCell(v reflect.Value, opts opt.TextOptions) (string, error) {
+	if m, ok := v.Interface().(textWithMarshaler); ok {
+		text, err := m.MarshalTextWith(opts)
+		return string(text), err
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return string(textfmt.Null(opts)), nil
+		}
+		v = v.Elem()
+	}
+
+	text, err := textfmt.Value(opts, v.Interface())
+	return string(text), err
+}
+
+// decodeCell decodes cell into the field v, see encodeCell.
+func decodeCell(v reflect.Value, cell string, opts opt.TextOptions) error {
+	if len(cell) == 0 && !hasEmpty(v.Type()) {
+		return nil
+	}
+
+	// Never nil so the Vals don't treat an empty cell as absent
+	text := append([]byte{}, cell...)
+
+	if u, ok := v.Addr().Interface().(textWithUnmarshaler); ok {
+		return u.UnmarshalTextWith(text, opts)
+	}
+
+	text, null := textfmt.Parse(opts, text)
+	if v.Kind() == reflect.Pointer {
+		if null {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	} else if null {
+		return errNullPlain
+	}
+
+	dst := v.Addr().Interface()
+	if u, ok := dst.(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(text)
+	}
+	return opt.ConvertAssign(dst, string(text))
+}
+
+// hasEmpty reports whether an empty cell is a value of the field type t,
+// which is only true for strings and byte slices.
+func hasEmpty(t reflect.Type) bool {
+	if optreflect.KindOf(t) != optreflect.KindNone {
+		t = optreflect.ElemType(t)
+	} else if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.String ||
+		(t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+}
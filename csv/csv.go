@@ -0,0 +1,233 @@
+// Package csv reads and writes rows of CSV as structs of omit, null and
+// omitnull values using encoding/csv.
+//
+// Columns are matched to fields by the header row. Names come from the `csv`
+// struct tag (see Options.Tag) and fall back to the Go field name. Fields
+// tagged "-" are skipped.
+//
+// Cells are encoded the same way as the MarshalTextWith methods of the Val
+// types: null is written as a marker (\N by default, as used by MySQL and
+// Postgres dumps), an empty cell is an empty string and a value that would be
+// mistaken for the marker is escaped, see opt.TextOptions.
+//
+// When reading, a column that is missing from the header leaves its field
+// untouched, so omit and omitnull values stay unset. The marker makes a null
+// or omitnull value null (and a pointer nil), it is an error for an omit
+// value. Any other cell is decoded with the inner type's UnmarshalText, or
+// opt.ConvertAssign if it does not have one.
+//
+// CSV cannot leave a single cell out of a row, so unset values are written as
+// an empty cell. An empty cell is only read as a value for strings and byte
+// slices, for every other type it leaves the field untouched the same way a
+// missing column does.
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// TagName is the default struct tag that column names are read from.
+const TagName = "csv"
+
+var (
+	// ErrNotStruct is returned when the row type is not a struct.
+	ErrNotStruct = errors.New("csv: row type must be a struct")
+	// ErrExtraCells is returned when a row has more cells than the header,
+	// which encoding/csv allows when FieldsPerRecord is negative.
+	ErrExtraCells = errors.New("csv: row has more cells than the header")
+)
+
+// Options control how rows are read and written.
+type Options struct {
+	// Text sets the null marker and the escape for values that collide
+	// with it, opt.TextPostgres (\N) if it is the zero value.
+	Text opt.TextOptions
+	// Tag is the struct tag to read column names from, TagName if empty.
+	Tag string
+}
+
+func (o Options) text() opt.TextOptions {
+	if o.Text == (opt.TextOptions{}) {
+		return opt.TextPostgres
+	}
+	return o.Text
+}
+
+func (o Options) tag() string {
+	if len(o.Tag) == 0 {
+		return TagName
+	}
+	return o.Tag
+}
+
+// Error is a problem with a single cell, or a whole row when Column is empty.
+type Error struct {
+	// Row is the 1-based number of the row, not counting the header.
+	Row int
+	// Column is the name of the column from the header.
+	Column string
+	Err    error
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	if len(e.Column) == 0 {
+		return fmt.Sprintf("csv: row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("csv: row %d, column %q: %v", e.Row, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Writer writes structs of type T as CSV rows.
+type Writer[T any] struct {
+	w      *csv.Writer
+	opts   Options
+	fields []optreflect.Field
+	row    int
+}
+
+// NewWriter creates a Writer that writes to w, which can be configured
+// beforehand (for example to change the delimiter).
+func NewWriter[T any](w *csv.Writer, opts Options) (*Writer[T], error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+	return &Writer[T]{w: w, opts: opts, fields: optreflect.Fields(t, opts.tag())}, nil
+}
+
+// Write writes row, preceded by the header if it is the first row.
+func (w *Writer[T]) Write(row T) error {
+	if w.row == 0 {
+		header := make([]string, len(w.fields))
+		for i, f := range w.fields {
+			header[i] = f.Name
+		}
+		if err := w.w.Write(header); err != nil {
+			return err
+		}
+	}
+	w.row++
+
+	rv := reflect.ValueOf(row)
+	record := make([]string, len(w.fields))
+	for i, f := range w.fields {
+		field, _ := f.Value(rv)
+		cell, err := encodeCell(field, w.opts.text())
+		if err != nil {
+			return &Error{Row: w.row, Column: f.Name, Err: err}
+		}
+		record[i] = cell
+	}
+	return w.w.Write(record)
+}
+
+// WriteAll writes all the rows and flushes the underlying writer.
+func (w *Writer[T]) WriteAll(rows []T) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Flush flushes the underlying writer and returns any error it had.
+func (w *Writer[T]) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// Reader reads CSV rows into structs of type T.
+type Reader[T any] struct {
+	r       *csv.Reader
+	opts    Options
+	header  []string
+	columns []*optreflect.Field
+	row     int
+}
+
+// NewReader creates a Reader that reads from r, which can be configured
+// beforehand (for example to change the delimiter). The header row is read
+// immediately.
+func NewReader[T any](r *csv.Reader, opts Options) (*Reader[T], error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := optreflect.Fields(t, opts.tag())
+	columns := make([]*optreflect.Field, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		if seen[name] {
+			return nil, fmt.Errorf("csv: duplicate column %q in header", name)
+		}
+		seen[name] = true
+
+		if f, ok := optreflect.FieldByName(fields, name); ok {
+			columns[i] = &f
+		}
+	}
+
+	return &Reader[T]{r: r, opts: opts, header: header, columns: columns}, nil
+}
+
+// Read reads the next row, it returns io.EOF when there are no more.
+// Columns that are not in T are ignored.
+func (r *Reader[T]) Read() (T, error) {
+	var row T
+
+	record, err := r.r.Read()
+	if err != nil {
+		return row, err
+	}
+	r.row++
+
+	if len(record) > len(r.columns) {
+		return row, &Error{Row: r.row, Err: ErrExtraCells}
+	}
+
+	rv := reflect.ValueOf(&row).Elem()
+	for i, cell := range record {
+		f := r.columns[i]
+		if f == nil {
+			continue
+		}
+		if err := decodeCell(f.Alloc(rv), cell, r.opts.text()); err != nil {
+			return row, &Error{Row: r.row, Column: r.header[i], Err: err}
+		}
+	}
+
+	return row, nil
+}
+
+// ReadAll reads all the remaining rows.
+func (r *Reader[T]) ReadAll() ([]T, error) {
+	var rows []T
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		} else if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,215 @@
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type testRow struct {
+	ID       int                     `csv:"id"`
+	Name     omit.Val[string]        `csv:"name"`
+	Note     null.Val[string]        `csv:"note"`
+	Amount   omitnull.Val[float64]   `csv:"amount"`
+	Paid     omitnull.Val[time.Time] `csv:"paid"`
+	Ref      *string                 `csv:"ref"`
+	Internal string                  `csv:"-"`
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	ref := `\x`
+	rows := []testRow{
+		{
+			ID:     1,
+			Name:   omit.From("a,b"),
+			Note:   null.From(""),
+			Amount: omitnull.From(1.5),
+			Paid:   omitnull.From(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+			Ref:    &ref,
+		},
+		{ID: 2, Note: null.From(`\N`), Amount: omitnull.FromPtr[float64](nil), Internal: "x"},
+	}
+
+	var sb strings.Builder
+	w, err := NewWriter[testRow](csv.NewWriter(&sb), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `id,name,note,amount,paid,ref
+1,"a,b",,1.5,2024-01-02T03:04:05Z,\\x
+2,,\\N,\N,,\N
+`
+	if got := sb.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	in := `ref,amount,note,extra,id
+\\x,1.5,,ignored,1
+\N,\N,\N,,2
+,,\\N,,3
+`
+	r, err := NewReader[testRow](csv.NewReader(strings.NewReader(in)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, empty := `\x`, ""
+	want := []testRow{
+		{ID: 1, Note: null.From(""), Amount: omitnull.From(1.5), Ref: &ref},
+		{ID: 2, Note: null.FromPtr[string](nil), Amount: omitnull.FromPtr[float64](nil)},
+		{ID: 3, Note: null.From(`\N`), Ref: &empty},
+	}
+	if !reflect.DeepEqual(want, rows) {
+		t.Errorf("want: %#v\ngot:  %#v", want, rows)
+	}
+
+	for i, row := range rows {
+		if !row.Name.IsUnset() {
+			t.Errorf("%d) missing column should leave name unset", i)
+		}
+		if !row.Paid.IsUnset() {
+			t.Errorf("%d) missing column should leave paid unset", i)
+		}
+	}
+	if !rows[2].Amount.IsUnset() {
+		t.Error("empty cell should leave amount unset")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	in := []testRow{
+		{ID: 1, Name: omit.From(`\`), Note: null.From("NULL"), Amount: omitnull.From(-2.0)},
+		{ID: 2, Name: omit.From(""), Paid: omitnull.FromPtr[time.Time](nil)},
+		{ID: 3, Name: omit.From("x"), Note: null.From("")},
+	}
+	opts := Options{Text: opt.TextSQL}
+
+	var sb strings.Builder
+	w, err := NewWriter[testRow](csv.NewWriter(&sb), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteAll(in); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader[testRow](csv.NewReader(strings.NewReader(sb.String())), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("want: %#v\ngot:  %#v", in, out)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		in     string
+		row    int
+		column string
+	}{
+		{"bad int", "id\n1\nx\n", 2, "id"},
+		{"null into omit", "id,name\n1,a\n2,\\N\n", 2, "name"},
+		{"null into plain", "id\n\\N\n", 1, "id"},
+		{"bad float", "amount,id\n1.5,1\n2,2\nnope,3\n", 3, "amount"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader[testRow](csv.NewReader(strings.NewReader(test.in)), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = r.ReadAll()
+
+			var cellErr *Error
+			if !errors.As(err, &cellErr) {
+				t.Fatal("expected a cell error, got:", err)
+			}
+			if cellErr.Row != test.row || cellErr.Column != test.column {
+				t.Errorf("want row %d column %q, got: %v", test.row, test.column, err)
+			}
+		})
+	}
+}
+
+func TestReadExtraCells(t *testing.T) {
+	t.Parallel()
+
+	cr := csv.NewReader(strings.NewReader("id\n1\n2,x\n"))
+	cr.FieldsPerRecord = -1
+	r, err := NewReader[testRow](cr, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := r.ReadAll()
+	var rowErr *Error
+	if !errors.As(err, &rowErr) || rowErr.Row != 2 || !errors.Is(err, ErrExtraCells) {
+		t.Fatal("expected an error for row 2, got:", err)
+	}
+	if len(rows) != 1 {
+		t.Errorf("the first row should have been read: %#v", rows)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewReader[int](csv.NewReader(strings.NewReader("id\n")), Options{}); !errors.Is(err, ErrNotStruct) {
+		t.Error("expected ErrNotStruct, got:", err)
+	}
+	if _, err := NewReader[testRow](csv.NewReader(strings.NewReader("id,id\n")), Options{}); err == nil {
+		t.Error("expected an error for a duplicate column")
+	}
+	if _, err := NewReader[testRow](csv.NewReader(strings.NewReader("")), Options{}); !errors.Is(err, io.EOF) {
+		t.Error("expected io.EOF for a missing header, got:", err)
+	}
+}
+
+func TestWriteNoEscape(t *testing.T) {
+	t.Parallel()
+
+	w, err := NewWriter[testRow](csv.NewWriter(io.Discard), Options{Text: opt.TextOptions{Null: "-"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cellErr *Error
+	err = w.Write(testRow{Name: omit.From("-")})
+	if !errors.As(err, &cellErr) || cellErr.Row != 1 || cellErr.Column != "name" {
+		t.Error("expected an error for name in row 1, got:", err)
+	}
+}
@@ -0,0 +1,44 @@
+package opt
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DecodeError is returned by the decoding methods of the Val types (Scan,
+// UnmarshalJSON, UnmarshalText and so on) when they fail. Decoding is
+// all or nothing: when an error is returned the Val keeps the state and value
+// it had before the call.
+type DecodeError struct {
+	// Source is the Go type that was being decoded, for example []byte for
+	// UnmarshalJSON or the driver.Value type for Scan. Methods that are
+	// handed a decoder rather than the data report what they were handed:
+	// xml.StartElement or xml.Attr for XML and *jsontext.Decoder for
+	// UnmarshalJSONFrom. It is nil when scanning a NULL and for
+	// UnmarshalYAML, which only sees a callback.
+	Source reflect.Type
+	// Target is the type wrapped by the Val.
+	Target reflect.Type
+	// State is the state that was being decoded ("set", "null" or
+	// "unset"), it is empty when the input was too malformed to tell.
+	State string
+	Err   error
+}
+
+// Error implements error
+func (e *DecodeError) Error() string {
+	source := "nil"
+	if e.Source != nil {
+		source = e.Source.String()
+	}
+
+	if len(e.State) == 0 {
+		return fmt.Sprintf("opt: decoding %s into %s: %v", source, e.Target, e.Err)
+	}
+	return fmt.Sprintf("opt: decoding %s into %s %s: %v", source, e.State, e.Target, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
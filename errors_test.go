@@ -0,0 +1,39 @@
+package opt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	t.Parallel()
+
+	inner := errors.New("invalid syntax")
+	tests := []struct {
+		err  *DecodeError
+		want string
+	}{
+		{
+			&DecodeError{Source: reflect.TypeFor[string](), Target: reflect.TypeFor[int](), State: "set", Err: inner},
+			"opt: decoding string into set int: invalid syntax",
+		},
+		{
+			&DecodeError{Source: reflect.TypeFor[[]byte](), Target: reflect.TypeFor[int](), Err: inner},
+			"opt: decoding []uint8 into int: invalid syntax",
+		},
+		{
+			&DecodeError{Target: reflect.TypeFor[int](), State: "null", Err: inner},
+			"opt: decoding nil into null int: invalid syntax",
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.err.Error(); got != test.want {
+			t.Errorf("want: %s got: %s", test.want, got)
+		}
+		if !errors.Is(test.err, inner) {
+			t.Error("should unwrap to the inner error")
+		}
+	}
+}
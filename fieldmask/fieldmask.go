@@ -0,0 +1,192 @@
+// Package fieldmask converts between structs of omit, null and omitnull values
+// and the dotted paths used by google.protobuf.FieldMask.
+//
+// Path segments are matched against the json names of fields and nested
+// paths like address.city resolve through Val fields whose T is a struct,
+// plain struct fields and pointers to structs.
+package fieldmask
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// ErrUnknownPath is returned when a path does not resolve to a field.
+var ErrUnknownPath = errors.New("fieldmask: unknown path")
+
+// FromPatch returns the mask paths for every field of the struct patch that
+// is not unset, in field order.
+//
+// When a set field holds a struct that has Val fields it is treated as a
+// nested patch and the paths of its fields are returned instead of its own.
+func FromPatch(patch any) []string {
+	rv := reflect.Indirect(reflect.ValueOf(patch))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var paths []string
+	fromPatch(&paths, rv, "")
+	return paths
+}
+
+func fromPatch(paths *[]string, rv reflect.Value, prefix string) {
+	for _, f := range optreflect.Fields(rv.Type(), "json") {
+		path := prefix + f.Name
+		field, ok := f.Value(rv)
+		if !ok {
+			continue
+		}
+
+		if optreflect.KindOf(f.Type) == optreflect.KindNone {
+			if inner := reflect.Indirect(field); inner.Kind() == reflect.Struct {
+				fromPatch(paths, inner, path+".")
+			}
+			continue
+		}
+
+		state, value := optreflect.Get(field)
+		switch {
+		case state == optreflect.StateUnset:
+		case state == optreflect.StateSet && optreflect.HasVals(value.Type(), "json"):
+			fromPatch(paths, value, path+".")
+		default:
+			*paths = append(*paths, path)
+		}
+	}
+}
+
+// ToPatch creates a patch of type P from a complete message msg and the mask
+// paths. Every field of P that is named by a path is copied from msg and all
+// others are left unset.
+//
+// Fields of msg may be plain values, pointers (where nil is null) or any of
+// the Val types. When a path passes through a null parent in msg the
+// corresponding field of P is made null.
+func ToPatch[P any](msg any, paths []string) (P, error) {
+	var p P
+
+	pv := reflect.ValueOf(&p).Elem()
+	mv := reflect.Indirect(reflect.ValueOf(msg))
+	if pv.Kind() != reflect.Struct || mv.Kind() != reflect.Struct {
+		return p, errors.New("fieldmask: patch and message must be structs")
+	}
+
+	for _, path := range paths {
+		if err := toPatch(pv, mv, strings.Split(path, ".")); err != nil {
+			return p, fmt.Errorf("%w: %s", err, path)
+		}
+	}
+
+	return p, nil
+}
+
+func toPatch(pv, mv reflect.Value, segments []string) error {
+	pf, ok := optreflect.FieldByName(optreflect.Fields(pv.Type(), "json"), segments[0])
+	if !ok {
+		return ErrUnknownPath
+	}
+	mf, ok := optreflect.FieldByName(optreflect.Fields(mv.Type(), "json"), segments[0])
+	if !ok {
+		return ErrUnknownPath
+	}
+
+	pField := pf.Alloc(pv)
+	mField, _ := mf.Value(mv)
+	state, mValue := optreflect.ModelState(mField)
+
+	kind := optreflect.KindOf(pf.Type)
+	if len(segments) == 1 {
+		if kind == optreflect.KindNone {
+			return ErrUnknownPath
+		}
+		return assign(pField, state, mValue)
+	}
+
+	if kind == optreflect.KindNone {
+		inner := pField
+		if inner.Kind() == reflect.Pointer {
+			if inner.IsNil() {
+				inner.Set(reflect.New(inner.Type().Elem()))
+			}
+			inner = inner.Elem()
+		}
+		if inner.Kind() != reflect.Struct || state != optreflect.StateSet || mValue.Kind() != reflect.Struct {
+			return ErrUnknownPath
+		}
+		return toPatch(inner, mValue, segments[1:])
+	}
+
+	elem := optreflect.ElemType(pf.Type)
+	if elem.Kind() != reflect.Struct {
+		return ErrUnknownPath
+	}
+
+	switch state {
+	case optreflect.StateUnset:
+		return nil
+	case optreflect.StateNull:
+		return assign(pField, state, mValue)
+	}
+	if mValue.Kind() != reflect.Struct {
+		return ErrUnknownPath
+	}
+
+	sub := reflect.New(elem).Elem()
+	if pState, cur := optreflect.Get(pField); pState == optreflect.StateSet {
+		sub.Set(cur)
+	}
+	if err := toPatch(sub, mValue, segments[1:]); err != nil {
+		return err
+	}
+	optreflect.Set(pField, sub)
+	return nil
+}
+
+// assign sets the Val in field to the state and value given. When the value
+// is a struct that cannot be assigned to the Val's T but T is a patch struct
+// every field of T is filled from the value.
+func assign(field reflect.Value, state optreflect.State, value reflect.Value) error {
+	switch state {
+	case optreflect.StateUnset:
+		return nil
+	case optreflect.StateNull:
+		if !optreflect.KindOf(field.Type()).CanNull() {
+			return errors.New("fieldmask: field cannot hold null")
+		}
+		optreflect.SetNull(field)
+		return nil
+	}
+
+	elem := optreflect.ElemType(field.Type())
+	if value.Type().AssignableTo(elem) {
+		optreflect.Set(field, value)
+		return nil
+	}
+
+	if !optreflect.HasVals(elem, "json") || value.Kind() != reflect.Struct {
+		return fmt.Errorf("fieldmask: cannot assign %s to %s", value.Type(), elem)
+	}
+
+	sub := reflect.New(elem).Elem()
+	for _, f := range optreflect.Fields(elem, "json") {
+		if optreflect.KindOf(f.Type) == optreflect.KindNone {
+			continue
+		}
+		mf, ok := optreflect.FieldByName(optreflect.Fields(value.Type(), "json"), f.Name)
+		if !ok {
+			continue
+		}
+		mField, _ := mf.Value(value)
+		state, v := optreflect.ModelState(mField)
+		if err := assign(f.Alloc(sub), state, v); err != nil {
+			return err
+		}
+	}
+	optreflect.Set(field, sub)
+	return nil
+}
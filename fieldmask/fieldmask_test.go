@@ -0,0 +1,145 @@
+package fieldmask
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type addressPatch struct {
+	City omitnull.Val[string] `json:"city"`
+	Zip  omitnull.Val[string] `json:"zip"`
+}
+
+type userPatch struct {
+	Name    omit.Val[string]           `json:"name"`
+	Age     omitnull.Val[int]          `json:"age"`
+	Address omitnull.Val[addressPatch] `json:"address"`
+	Tags    omitnull.Val[[]string]     `json:"tags"`
+}
+
+type address struct {
+	City string  `json:"city"`
+	Zip  *string `json:"zip"`
+}
+
+type user struct {
+	Name    string         `json:"name"`
+	Age     null.Val[int]  `json:"age"`
+	Address *address       `json:"address"`
+	Tags    []string       `json:"tags"`
+	Ignored omit.Val[bool] `json:"ignored"`
+}
+
+func TestFromPatch(t *testing.T) {
+	t.Parallel()
+
+	p := userPatch{
+		Name:    omit.From("bob"),
+		Age:     omitnull.FromPtr[int](nil),
+		Address: omitnull.From(addressPatch{City: omitnull.From("Toronto")}),
+	}
+
+	want := []string{"name", "age", "address.city"}
+	if got := FromPatch(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("mask wrong\nwant: %#v\ngot:  %#v", want, got)
+	}
+
+	p.Address.Null()
+	want = []string{"name", "age", "address"}
+	if got := FromPatch(&p); !reflect.DeepEqual(got, want) {
+		t.Errorf("mask wrong\nwant: %#v\ngot:  %#v", want, got)
+	}
+
+	if FromPatch(5) != nil {
+		t.Error("expected nothing for a non-struct")
+	}
+}
+
+func TestToPatch(t *testing.T) {
+	t.Parallel()
+
+	zip := "M5V"
+	msg := user{
+		Name:    "bob",
+		Age:     null.From(5),
+		Address: &address{City: "Toronto", Zip: &zip},
+		Tags:    []string{"a"},
+	}
+
+	p, err := ToPatch[userPatch](msg, []string{"age", "address.city"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Name.IsUnset() || !p.Tags.IsUnset() {
+		t.Error("fields outside the mask should be unset")
+	}
+	if p.Age.MustGet() != 5 {
+		t.Error("age wrong")
+	}
+	addr := p.Address.MustGet()
+	if addr.City.MustGet() != "Toronto" || !addr.Zip.IsUnset() {
+		t.Errorf("address wrong: %#v", addr)
+	}
+
+	// A whole nested struct is converted field by field
+	p, err = ToPatch[userPatch](&msg, []string{"address", "tags"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = p.Address.MustGet()
+	if addr.City.MustGet() != "Toronto" || addr.Zip.MustGet() != "M5V" {
+		t.Errorf("address wrong: %#v", addr)
+	}
+	if p.Tags.MustGet()[0] != "a" {
+		t.Error("tags wrong")
+	}
+
+	// Null parents and null values
+	msg.Address = nil
+	msg.Age = null.Val[int]{}
+	p, err = ToPatch[userPatch](msg, []string{"age", "address.city"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Age.IsNull() || !p.Address.IsNull() {
+		t.Errorf("expected nulls: %#v", p)
+	}
+
+	// Round trip the mask
+	msg.Address = &address{City: "Ottawa"}
+	p, err = ToPatch[userPatch](msg, []string{"address.city", "address.zip", "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"name", "address.city", "address.zip"}
+	if got := FromPatch(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("mask wrong\nwant: %#v\ngot:  %#v", want, got)
+	}
+}
+
+func TestToPatchErrors(t *testing.T) {
+	t.Parallel()
+
+	msg := user{Address: &address{}}
+	for _, path := range []string{"missing", "ignored", "address.missing", "name.first"} {
+		if _, err := ToPatch[userPatch](msg, []string{path}); !errors.Is(err, ErrUnknownPath) {
+			t.Errorf("%s: expected unknown path error, got: %v", path, err)
+		}
+	}
+
+	if _, err := ToPatch[int](msg, nil); err == nil {
+		t.Error("expected an error for a non-struct patch")
+	}
+
+	type omitPatch struct {
+		Age omit.Val[int] `json:"age"`
+	}
+	if _, err := ToPatch[omitPatch](msg, []string{"age"}); err == nil {
+		t.Error("expected an error storing null in omit")
+	}
+}
@@ -0,0 +1,102 @@
+// Package appender appends the text and binary representations of values
+// to byte slices, avoiding reflection and intermediate allocations for the
+// common primitive types.
+package appender
+
+import (
+	"encoding"
+	"strconv"
+
+	"github.com/aarondl/opt"
+)
+
+// Text appends the text representation of v to b.
+//
+// Go primitives are formatted with strconv, encoding.TextAppender and
+// encoding.TextMarshaler are used when v implements them and anything else is
+// converted with opt.ConvertAssign.
+func Text[T any](b []byte, v T) ([]byte, error) {
+	if out, ok := primitive(b, &v); ok {
+		return out, nil
+	}
+
+	switch val := any(v).(type) {
+	case encoding.TextAppender:
+		return val.AppendText(b)
+	case encoding.TextMarshaler:
+		text, err := val.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return append(b, text...), nil
+	}
+
+	var text string
+	if err := opt.ConvertAssign(&text, v); err != nil {
+		return nil, err
+	}
+	return append(b, text...), nil
+}
+
+// Binary appends the binary representation of v to b.
+//
+// encoding.BinaryAppender and encoding.BinaryMarshaler are preferred when v
+// implements them, failing that the text representation is used as in Text.
+func Binary[T any](b []byte, v T) ([]byte, error) {
+	if out, ok := primitive(b, &v); ok {
+		return out, nil
+	}
+
+	switch val := any(v).(type) {
+	case encoding.BinaryAppender:
+		return val.AppendBinary(b)
+	case encoding.BinaryMarshaler:
+		bin, err := val.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return append(b, bin...), nil
+	}
+
+	return Text(b, v)
+}
+
+// primitive appends the same representation of the value pointed to by ptr
+// that opt.ConvertAssign would produce when converting it to a string. It
+// takes a pointer to avoid boxing the value in an interface.
+func primitive(b []byte, ptr any) ([]byte, bool) {
+	switch val := ptr.(type) {
+	case *string:
+		return append(b, *val...), true
+	case *[]byte:
+		return append(b, *val...), true
+	case *bool:
+		return strconv.AppendBool(b, *val), true
+	case *int:
+		return strconv.AppendInt(b, int64(*val), 10), true
+	case *int8:
+		return strconv.AppendInt(b, int64(*val), 10), true
+	case *int16:
+		return strconv.AppendInt(b, int64(*val), 10), true
+	case *int32:
+		return strconv.AppendInt(b, int64(*val), 10), true
+	case *int64:
+		return strconv.AppendInt(b, *val, 10), true
+	case *uint:
+		return strconv.AppendUint(b, uint64(*val), 10), true
+	case *uint8:
+		return strconv.AppendUint(b, uint64(*val), 10), true
+	case *uint16:
+		return strconv.AppendUint(b, uint64(*val), 10), true
+	case *uint32:
+		return strconv.AppendUint(b, uint64(*val), 10), true
+	case *uint64:
+		return strconv.AppendUint(b, *val, 10), true
+	case *float32:
+		return strconv.AppendFloat(b, float64(*val), 'g', -1, 32), true
+	case *float64:
+		return strconv.AppendFloat(b, *val, 'g', -1, 64), true
+	}
+
+	return b, false
+}
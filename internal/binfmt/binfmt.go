@@ -0,0 +1,380 @@
+// Package binfmt implements the versioned binary format used by the Val types'
+// MarshalBinary and UnmarshalBinary.
+//
+// An encoded Val is laid out as:
+//
+//	magic (3 bytes) | version (1 byte) | state (1 byte) | kind (1 byte) | payload
+//
+// magic is always Magic and version is Version, they let decoders tell the
+// format apart from the legacy encodings that were written without a header.
+// Magic starts with 0xff which cannot start UTF-8 text, so only legacy values
+// of []byte or encoding.BinaryMarshaler types can be mistaken for the format,
+// and only when they start with the same three bytes.
+//
+// The kind and payload are only present when the state is StateSet, and the
+// kind describes the payload:
+//
+//	kind    | payload
+//	--------|--------------------------------------------------------------
+//	varint  | zig-zag varint (signed integers)
+//	uvarint | varint (unsigned integers)
+//	float32 | 4 byte big endian IEEE-754
+//	float64 | 8 byte big endian IEEE-754
+//	bool    | 1 byte, 0 or 1
+//	string  | varint length, UTF-8 bytes
+//	bytes   | varint length, bytes
+//	time    | 8 byte big endian unix seconds, 4 byte nanoseconds, 1 byte
+//	        | UTC flag and 4 byte zone offset in seconds
+//	binary  | varint length, output of encoding.BinaryMarshaler
+//	text    | varint length, the text representation as in MarshalText
+package binfmt
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/aarondl/opt/internal/appender"
+	"github.com/aarondl/opt/internal/decode"
+)
+
+// Magic is the start of every encoded value
+const Magic = "\xffop"
+
+// Version follows Magic, it is bumped when the layout changes
+const Version byte = 1
+
+// headerLen is the length of the magic and version
+const headerLen = len(Magic) + 1
+
+// States, these are the same for all the Val types so values can be decoded
+// into a different Val type than they were encoded from.
+const (
+	StateUnset byte = 0
+	StateNull  byte = 1
+	StateSet   byte = 2
+)
+
+// kinds of payload
+const (
+	kindVarint byte = iota + 1
+	kindUvarint
+	kindFloat32
+	kindFloat64
+	kindBool
+	kindString
+	kindBytes
+	kindTime
+	kindBinary
+	kindText
+)
+
+// ErrFormat is returned when the data is not valid.
+var ErrFormat = errors.New("invalid binary format")
+
+// IsVersioned returns true if b starts with Magic.
+func IsVersioned(b []byte) bool {
+	return len(b) >= len(Magic) && string(b[:len(Magic)]) == Magic
+}
+
+// Append appends the encoding of a Val in state with value v to b. v is only
+// used when state is StateSet.
+func Append[T any](b []byte, state byte, v T) ([]byte, error) {
+	b = append(append(b, Magic...), Version, state)
+	if state != StateSet {
+		return b, nil
+	}
+
+	if out, ok := primitive(b, &v); ok {
+		return out, nil
+	}
+
+	switch val := any(v).(type) {
+	case encoding.BinaryAppender:
+		return appendLen(b, kindBinary, val.AppendBinary)
+	case encoding.BinaryMarshaler:
+		return appendLen(b, kindBinary, func(b []byte) ([]byte, error) {
+			bin, err := val.MarshalBinary()
+			return append(b, bin...), err
+		})
+	}
+
+	if out, ok := reflectPrimitive(b, reflect.ValueOf(v)); ok {
+		return out, nil
+	}
+
+	return appendLen(b, kindText, func(b []byte) ([]byte, error) {
+		return appender.Text(b, v)
+	})
+}
+
+// Decode decodes data produced by Append, it must start with Magic.
+func Decode[T any](b []byte) (state byte, v T, err error) {
+	if len(b) < headerLen+1 || !IsVersioned(b) {
+		return 0, v, fmt.Errorf("%w: missing header", ErrFormat)
+	}
+	if version := b[len(Magic)]; version != Version {
+		return 0, v, fmt.Errorf("%w: unknown version %d", ErrFormat, version)
+	}
+	b = b[headerLen:]
+
+	state = b[0]
+	switch state {
+	case StateUnset, StateNull:
+		if len(b) != 1 {
+			return 0, v, fmt.Errorf("%w: unexpected value", ErrFormat)
+		}
+		return state, v, nil
+	case StateSet:
+	default:
+		return 0, v, fmt.Errorf("%w: unknown state %d", ErrFormat, state)
+	}
+
+	if len(b) < 2 {
+		return 0, v, fmt.Errorf("%w: missing kind", ErrFormat)
+	}
+	kind, payload := b[1], b[2:]
+
+	switch kind {
+	case kindBinary, kindText, kindString, kindBytes:
+		payload, err = readLen(payload)
+		if err != nil {
+			return 0, v, err
+		}
+	}
+
+	switch kind {
+	case kindBinary:
+		u, ok := any(&v).(encoding.BinaryUnmarshaler)
+		if !ok {
+			return 0, v, fmt.Errorf("%w: %T does not implement encoding.BinaryUnmarshaler", ErrFormat, v)
+		}
+		err = u.UnmarshalBinary(payload)
+	case kindText:
+		err = decode.Text(&v, payload)
+	default:
+		err = decodePrimitive(reflect.ValueOf(&v).Elem(), kind, payload)
+	}
+	if err != nil {
+		return 0, v, err
+	}
+
+	return state, v, nil
+}
+
+// primitive appends the Go primitives and time.Time, it takes a pointer to
+// avoid boxing the value in an interface.
+func primitive(b []byte, ptr any) ([]byte, bool) {
+	switch val := ptr.(type) {
+	case *string:
+		return append(binary.AppendUvarint(append(b, kindString), uint64(len(*val))), *val...), true
+	case *[]byte:
+		return append(binary.AppendUvarint(append(b, kindBytes), uint64(len(*val))), *val...), true
+	case *bool:
+		return appendBool(b, *val), true
+	case *int:
+		return binary.AppendVarint(append(b, kindVarint), int64(*val)), true
+	case *int8:
+		return binary.AppendVarint(append(b, kindVarint), int64(*val)), true
+	case *int16:
+		return binary.AppendVarint(append(b, kindVarint), int64(*val)), true
+	case *int32:
+		return binary.AppendVarint(append(b, kindVarint), int64(*val)), true
+	case *int64:
+		return binary.AppendVarint(append(b, kindVarint), *val), true
+	case *uint:
+		return binary.AppendUvarint(append(b, kindUvarint), uint64(*val)), true
+	case *uint8:
+		return binary.AppendUvarint(append(b, kindUvarint), uint64(*val)), true
+	case *uint16:
+		return binary.AppendUvarint(append(b, kindUvarint), uint64(*val)), true
+	case *uint32:
+		return binary.AppendUvarint(append(b, kindUvarint), uint64(*val)), true
+	case *uint64:
+		return binary.AppendUvarint(append(b, kindUvarint), *val), true
+	case *float32:
+		return binary.BigEndian.AppendUint32(append(b, kindFloat32), math.Float32bits(*val)), true
+	case *float64:
+		return binary.BigEndian.AppendUint64(append(b, kindFloat64), math.Float64bits(*val)), true
+	case *time.Time:
+		return appendTime(b, *val), true
+	}
+
+	return b, false
+}
+
+// reflectPrimitive appends named types whose underlying type is a primitive.
+func reflectPrimitive(b []byte, v reflect.Value) ([]byte, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return append(binary.AppendUvarint(append(b, kindString), uint64(v.Len())), v.String()...), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return b, false
+		}
+		return append(binary.AppendUvarint(append(b, kindBytes), uint64(v.Len())), v.Bytes()...), true
+	case reflect.Bool:
+		return appendBool(b, v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(append(b, kindVarint), v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.AppendUvarint(append(b, kindUvarint), v.Uint()), true
+	case reflect.Float32:
+		return binary.BigEndian.AppendUint32(append(b, kindFloat32), math.Float32bits(float32(v.Float()))), true
+	case reflect.Float64:
+		return binary.BigEndian.AppendUint64(append(b, kindFloat64), math.Float64bits(v.Float())), true
+	}
+
+	return b, false
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, kindBool, 1)
+	}
+	return append(b, kindBool, 0)
+}
+
+func appendTime(b []byte, t time.Time) []byte {
+	var utc byte
+	if t.Location() == time.UTC {
+		utc = 1
+	}
+	_, offset := t.Zone()
+
+	b = binary.BigEndian.AppendUint64(append(b, kindTime), uint64(t.Unix()))
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+	return binary.BigEndian.AppendUint32(append(b, utc), uint32(int32(offset)))
+}
+
+// appendLen appends kind, the length of what fn appends and then what fn
+// appends.
+func appendLen(b []byte, kind byte, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	data, err := fn(nil)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.AppendUvarint(append(b, kind), uint64(len(data)))
+	return append(b, data...), nil
+}
+
+// readLen reads a length prefixed payload, which must be all of b.
+func readLen(b []byte) ([]byte, error) {
+	n, size := binary.Uvarint(b)
+	if size <= 0 || n != uint64(len(b)-size) {
+		return nil, fmt.Errorf("%w: bad length", ErrFormat)
+	}
+	return b[size:], nil
+}
+
+// decodePrimitive decodes the payload of the primitive kinds into v
+// according to v's underlying type.
+func decodePrimitive(v reflect.Value, kind byte, payload []byte) error {
+	mismatch := func() error {
+		return fmt.Errorf("%w: cannot decode kind %d into %s", ErrFormat, kind, v.Type())
+	}
+
+	switch kind {
+	case kindVarint, kindUvarint:
+		var n int64
+		var u uint64
+		var size int
+		if kind == kindVarint {
+			n, size = binary.Varint(payload)
+		} else {
+			u, size = binary.Uvarint(payload)
+		}
+		if size <= 0 || size != len(payload) {
+			return fmt.Errorf("%w: bad varint", ErrFormat)
+		}
+
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if kind == kindUvarint {
+				if u > math.MaxInt64 {
+					return fmt.Errorf("%w: %d overflows %s", ErrFormat, u, v.Type())
+				}
+				n = int64(u)
+			}
+			if v.OverflowInt(n) {
+				return fmt.Errorf("%w: %d overflows %s", ErrFormat, n, v.Type())
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if kind == kindVarint {
+				if n < 0 {
+					return fmt.Errorf("%w: %d overflows %s", ErrFormat, n, v.Type())
+				}
+				u = uint64(n)
+			}
+			if v.OverflowUint(u) {
+				return fmt.Errorf("%w: %d overflows %s", ErrFormat, u, v.Type())
+			}
+			v.SetUint(u)
+		default:
+			return mismatch()
+		}
+	case kindFloat32, kindFloat64:
+		var f float64
+		if kind == kindFloat32 && len(payload) == 4 {
+			f = float64(math.Float32frombits(binary.BigEndian.Uint32(payload)))
+		} else if kind == kindFloat64 && len(payload) == 8 {
+			f = math.Float64frombits(binary.BigEndian.Uint64(payload))
+		} else {
+			return fmt.Errorf("%w: bad float", ErrFormat)
+		}
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			return mismatch()
+		}
+		v.SetFloat(f)
+	case kindBool:
+		if len(payload) != 1 || payload[0] > 1 {
+			return fmt.Errorf("%w: bad bool", ErrFormat)
+		}
+		if v.Kind() != reflect.Bool {
+			return mismatch()
+		}
+		v.SetBool(payload[0] == 1)
+	case kindString:
+		if v.Kind() != reflect.String {
+			return mismatch()
+		}
+		v.SetString(string(payload))
+	case kindBytes:
+		if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Uint8 {
+			return mismatch()
+		}
+		v.SetBytes(append([]byte{}, payload...))
+	case kindTime:
+		if v.Type() != reflect.TypeFor[time.Time]() {
+			return mismatch()
+		}
+		if len(payload) != 17 || payload[12] > 1 {
+			return fmt.Errorf("%w: bad time", ErrFormat)
+		}
+		v.Set(reflect.ValueOf(decodeTime(payload)))
+	default:
+		return fmt.Errorf("%w: unknown kind %d", ErrFormat, kind)
+	}
+
+	return nil
+}
+
+func decodeTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint64(b))
+	nanos := int64(binary.BigEndian.Uint32(b[8:]))
+	offset := int(int32(binary.BigEndian.Uint32(b[13:])))
+
+	t := time.Unix(secs, nanos)
+	if b[12] == 1 {
+		return t.UTC()
+	}
+	if _, local := t.Zone(); local == offset {
+		return t
+	}
+	return t.In(time.FixedZone("", offset))
+}
@@ -0,0 +1,31 @@
+// Package decode has helpers shared by the Val types to decode their
+// wrapped value from various formats.
+package decode
+
+import (
+	"encoding"
+
+	"github.com/aarondl/opt"
+)
+
+// Text decodes text into dst. If dst implements encoding.TextUnmarshaler it
+// is used, otherwise the text is converted with opt.ConvertAssign.
+func Text[T any](dst *T, text []byte) error {
+	if u, ok := any(dst).(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(text)
+	}
+	return opt.ConvertAssign(dst, string(text))
+}
+
+// Binary decodes b into dst. encoding.BinaryUnmarshaler is preferred when dst
+// implements it, failing that encoding.TextUnmarshaler and opt.ConvertAssign
+// are tried in that order.
+func Binary[T any](dst *T, b []byte) error {
+	switch u := any(dst).(type) {
+	case encoding.BinaryUnmarshaler:
+		return u.UnmarshalBinary(b)
+	case encoding.TextUnmarshaler:
+		return u.UnmarshalText(b)
+	}
+	return opt.ConvertAssign(dst, b)
+}
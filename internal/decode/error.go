@@ -0,0 +1,30 @@
+package decode
+
+import (
+	"reflect"
+
+	"github.com/aarondl/opt"
+)
+
+// Names of the states for opt.DecodeError
+const (
+	StateUnknown = ""
+	StateUnset   = "unset"
+	StateNull    = "null"
+	StateSet     = "set"
+)
+
+// Error wraps err in an *opt.DecodeError for decoding src into a Val[T] in
+// the given state. It returns nil if err is nil.
+func Error[T any](src any, state string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &opt.DecodeError{
+		Source: reflect.TypeOf(src),
+		Target: reflect.TypeFor[T](),
+		State:  state,
+		Err:    err,
+	}
+}
@@ -0,0 +1,22 @@
+package decode
+
+import (
+	"encoding/xml"
+
+	"github.com/aarondl/opt/internal/globaldata"
+)
+
+// XMLNil returns true if the attributes of an element contain xsi:nil with a
+// true value. The prefix is accepted whether or not it was declared.
+func XMLNil(attrs []xml.Attr) bool {
+	for _, a := range attrs {
+		if a.Name.Local != "nil" {
+			continue
+		}
+		if a.Name.Space != globaldata.XSINamespace && a.Name.Space != "xsi" {
+			continue
+		}
+		return a.Value == "true" || a.Value == "1"
+	}
+	return false
+}
@@ -7,10 +7,8 @@ import (
 )
 
 var (
-	JSONNull                      = []byte("null")
-	DriverValuerIntf              = reflect.TypeFor[driver.Valuer]()
-	EncodingTextMarshalerIntf     = reflect.TypeFor[encoding.TextMarshaler]()
-	EncodingTextUnmarshalerIntf   = reflect.TypeFor[encoding.TextUnmarshaler]()
-	EncodingBinaryMarshalerIntf   = reflect.TypeFor[encoding.BinaryMarshaler]()
-	EncodingBinaryUnmarshalerIntf = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+	JSONNull                    = []byte("null")
+	DriverValuerIntf            = reflect.TypeFor[driver.Valuer]()
+	EncodingTextMarshalerIntf   = reflect.TypeFor[encoding.TextMarshaler]()
+	EncodingBinaryMarshalerIntf = reflect.TypeFor[encoding.BinaryMarshaler]()
 )
@@ -0,0 +1,13 @@
+package globaldata
+
+import "encoding/xml"
+
+// XSINamespace is the XML Schema instance namespace that defines xsi:nil
+const XSINamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// XMLNilAttrs are added to an element to mark it as xsi:nil, the namespace is
+// declared on the element itself so the output is valid on its own.
+var XMLNilAttrs = []xml.Attr{
+	{Name: xml.Name{Local: "xmlns:xsi"}, Value: XSINamespace},
+	{Name: xml.Name{Local: "xsi:nil"}, Value: "true"},
+}
@@ -0,0 +1,208 @@
+package optreflect
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Field is an exported struct field along with the name it goes by.
+type Field struct {
+	reflect.StructField
+
+	// Name is the name from the struct tag, or the Go name when the tag
+	// does not provide one.
+	Name string
+	// Options are the comma separated values in the tag after the name.
+	Options []string
+
+	tagged bool
+}
+
+// HasOption returns true if the field's tag contained the option.
+func (f Field) HasOption(option string) bool {
+	return slices.Contains(f.Options, option)
+}
+
+// Value returns the field of the struct v. When the field is promoted through
+// a nil embedded pointer it returns the zero value of the field's type and
+// false.
+func (f Field) Value(v reflect.Value) (reflect.Value, bool) {
+	for i, x := range f.Index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Zero(f.Type), false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// Alloc returns the field of the struct v so that it can be set, allocating
+// any nil embedded pointers it is promoted through. v must be addressable.
+func (f Field) Alloc(v reflect.Value) reflect.Value {
+	for i, x := range f.Index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// Fields returns the exported fields of the struct type t. Names are taken
+// from the struct tag named by key, falling back to the Go field name.
+// Fields tagged "-" are skipped.
+//
+// Untagged embedded structs, and pointers to them, are flattened into their
+// parent following the rules of encoding/json: when several fields end up
+// with the same name the shallowest one wins, a tagged field wins over
+// untagged ones at the same depth and if that still leaves more than one
+// they are all dropped. The other packages in this module match fields by
+// name following these rules.
+//
+// The Index of each returned field is relative to t. Fields promoted through
+// an embedded pointer have to be accessed with Field.Value and Field.Alloc
+// since the pointer may be nil.
+func Fields(t reflect.Type, key string) []Field {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []Field
+	next := []embedded{{typ: t}}
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		for _, e := range current {
+			// a type embedded more than once at the same depth is walked
+			// each time so its fields end up ambiguous
+			if visited[e.typ] {
+				continue
+			}
+
+			for i := range e.typ.NumField() {
+				sf := e.typ.Field(i)
+				tag, hasTag := sf.Tag.Lookup(key)
+				if tag == "-" {
+					continue
+				}
+
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(slices.Clip(e.index), i)
+
+				if sf.Anonymous && name == "" {
+					st := sf.Type
+					if st.Kind() == reflect.Pointer {
+						st = st.Elem()
+					}
+					if st.Kind() == reflect.Struct && KindOf(st) == KindNone {
+						// embedded pointers must be exported to allocate them
+						if st == sf.Type || sf.IsExported() {
+							next = append(next, embedded{typ: st, index: index})
+						}
+						continue
+					}
+				}
+				if !sf.IsExported() {
+					continue
+				}
+
+				field := Field{StructField: sf, Name: name, tagged: name != ""}
+				if name == "" {
+					field.Name = sf.Name
+				}
+				field.Index = index
+				if hasTag && len(opts) != 0 {
+					field.Options = strings.Split(opts, ",")
+				}
+				fields = append(fields, field)
+			}
+		}
+		for _, e := range current {
+			visited[e.typ] = true
+		}
+	}
+
+	slices.SortStableFunc(fields, func(a, b Field) int {
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			cmp.Compare(len(a.Index), len(b.Index)),
+			compareBool(b.tagged, a.tagged),
+		)
+	})
+
+	var dominant []Field
+	for group := range chunkByName(fields) {
+		if f, ok := dominantField(group); ok {
+			dominant = append(dominant, f)
+		}
+	}
+
+	slices.SortFunc(dominant, func(a, b Field) int {
+		return slices.Compare(a.Index, b.Index)
+	})
+	return dominant
+}
+
+// chunkByName yields the runs of fields that share a name.
+func chunkByName(fields []Field) func(func([]Field) bool) {
+	return func(yield func([]Field) bool) {
+		for i := 0; i < len(fields); {
+			j := i + 1
+			for j < len(fields) && fields[j].Name == fields[i].Name {
+				j++
+			}
+			if !yield(fields[i:j]) {
+				return
+			}
+			i = j
+		}
+	}
+}
+
+// dominantField picks the field that wins among fields of the same name,
+// which are sorted by depth with tagged fields first.
+func dominantField(fields []Field) (Field, bool) {
+	if len(fields) > 1 && len(fields[0].Index) == len(fields[1].Index) &&
+		fields[0].tagged == fields[1].tagged {
+		return Field{}, false
+	}
+	return fields[0], true
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// FieldByName finds the field with the given name in fields.
+func FieldByName(fields []Field, name string) (Field, bool) {
+	for _, f := range fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Opaque returns true for struct types that have fields but none that
+// Fields(t, key) returns, an encoder would silently turn these into an empty
+// object.
+func Opaque(t reflect.Type, key string) bool {
+	return t.NumField() > 0 && len(Fields(t, key)) == 0
+}
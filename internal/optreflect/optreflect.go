@@ -0,0 +1,181 @@
+// Package optreflect helps other packages in this module work with the
+// omit, null and omitnull Val types through reflection when T is not known
+// at compile time.
+package optreflect
+
+import (
+	"reflect"
+	"strings"
+)
+
+const (
+	omitPkg     = "github.com/aarondl/opt/omit"
+	nullPkg     = "github.com/aarondl/opt/null"
+	omitnullPkg = "github.com/aarondl/opt/omitnull"
+)
+
+// Kind identifies which of the Val types a reflect.Type is.
+type Kind int
+
+const (
+	KindNone Kind = iota
+	KindOmit
+	KindNull
+	KindOmitNull
+)
+
+// State is a superset of the states of all three Val types.
+type State int
+
+const (
+	StateUnset State = iota
+	StateNull
+	StateSet
+)
+
+// String -er interface implementation
+func (s State) String() string {
+	switch s {
+	case StateUnset:
+		return "unset"
+	case StateNull:
+		return "null"
+	case StateSet:
+		return "set"
+	default:
+		panic("unknown")
+	}
+}
+
+type (
+	valuer interface{ IsValue() bool }
+	nuller interface{ IsNull() bool }
+)
+
+// KindOf returns the kind of Val that t is, KindNone if it is not one.
+func KindOf(t reflect.Type) Kind {
+	if t.Kind() != reflect.Struct || !strings.HasPrefix(t.Name(), "Val[") {
+		return KindNone
+	}
+
+	switch t.PkgPath() {
+	case omitPkg:
+		return KindOmit
+	case nullPkg:
+		return KindNull
+	case omitnullPkg:
+		return KindOmitNull
+	default:
+		return KindNone
+	}
+}
+
+// CanNull returns true if the kind is able to hold a null.
+func (k Kind) CanNull() bool {
+	return k == KindNull || k == KindOmitNull
+}
+
+// CanUnset returns true if the kind is able to be unset.
+func (k Kind) CanUnset() bool {
+	return k == KindOmit || k == KindOmitNull
+}
+
+// ElemType returns T for a Val[T] type. It must only be called with types
+// where KindOf returns something other than KindNone.
+func ElemType(t reflect.Type) reflect.Type {
+	m, _ := t.MethodByName("GetOrZero")
+	return m.Type.Out(0)
+}
+
+// Get the state of the Val in v as well as the value if it is set.
+func Get(v reflect.Value) (State, reflect.Value) {
+	iface := v.Interface()
+	if iface.(valuer).IsValue() {
+		return StateSet, v.MethodByName("GetOrZero").Call(nil)[0]
+	}
+	if n, ok := iface.(nuller); ok && n.IsNull() {
+		return StateNull, reflect.Value{}
+	}
+	return StateUnset, reflect.Value{}
+}
+
+// StateOf returns only the state of the Val in v.
+func StateOf(v reflect.Value) State {
+	iface := v.Interface()
+	if iface.(valuer).IsValue() {
+		return StateSet
+	}
+	if n, ok := iface.(nuller); ok && n.IsNull() {
+		return StateNull
+	}
+	return StateUnset
+}
+
+// ModelState interprets v, which may or may not be a Val, as one of the Val
+// states. Vals report their own state, nil pointers are null and anything
+// else is set, with pointers dereferenced.
+func ModelState(v reflect.Value) (State, reflect.Value) {
+	if KindOf(v.Type()) != KindNone {
+		return Get(v)
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return StateNull, reflect.Value{}
+		}
+		return StateSet, v.Elem()
+	}
+	return StateSet, v
+}
+
+// HasVals returns true if t is a struct with at least one Val field among
+// Fields(t, key).
+func HasVals(t reflect.Type, key string) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, f := range Fields(t, key) {
+		if KindOf(f.Type) != KindNone {
+			return true
+		}
+	}
+	return false
+}
+
+// Set the Val in v to x. v must be addressable and x must be assignable to
+// the Val's T.
+func Set(v reflect.Value, x reflect.Value) {
+	v.Addr().MethodByName("Set").Call([]reflect.Value{x})
+}
+
+// SetNull sets the Val in v to null. v must be addressable and its kind must
+// be able to hold a null.
+func SetNull(v reflect.Value) {
+	v.Addr().Interface().(interface{ Null() }).Null()
+}
+
+// SetUnset sets the Val in v to unset. v must be addressable and its kind
+// must be able to be unset.
+func SetUnset(v reflect.Value) {
+	v.Addr().Interface().(interface{ Unset() }).Unset()
+}
+
+// SetState sets v to the given state, using x as the value if the state is
+// StateSet. It returns false if v's kind cannot represent the state.
+func SetState(v reflect.Value, state State, x reflect.Value) bool {
+	kind := KindOf(v.Type())
+	switch state {
+	case StateSet:
+		Set(v, x)
+	case StateNull:
+		if !kind.CanNull() {
+			return false
+		}
+		SetNull(v)
+	default:
+		if !kind.CanUnset() {
+			return false
+		}
+		SetUnset(v)
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+// Package textfmt implements the interoperable text encoding described by
+// opt.TextOptions.
+package textfmt
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/appender"
+)
+
+// Null returns the text for a null value.
+func Null(opts opt.TextOptions) []byte {
+	return append([]byte{}, opts.Null...)
+}
+
+// Value returns the text for a set value, escaping it if it collides with
+// the null text.
+func Value[T any](opts opt.TextOptions, v T) ([]byte, error) {
+	text, err := appender.Text([]byte{}, v)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(text) != opts.Null && (len(opts.Escape) == 0 || !bytes.HasPrefix(text, []byte(opts.Escape))) {
+		return text, nil
+	}
+	if len(opts.Escape) == 0 {
+		return nil, fmt.Errorf("text %q cannot be distinguished from null without an escape", text)
+	}
+	return slices.Insert(text, 0, []byte(opts.Escape)...), nil
+}
+
+// Parse returns true if text is the null text, otherwise it returns the
+// text of the value with any escape removed.
+func Parse(opts opt.TextOptions, text []byte) ([]byte, bool) {
+	if string(text) == opts.Null {
+		return nil, true
+	}
+	if len(opts.Escape) != 0 {
+		if rest, ok := bytes.CutPrefix(text, []byte(opts.Escape)); ok {
+			return rest, false
+		}
+	}
+	return text, false
+}
@@ -0,0 +1,60 @@
+package opt
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// JSON stores its value in a JSON or JSONB column. It is meant to be wrapped
+// by the Val types, for example null.Val[opt.JSON[Settings]], to opt in to
+// JSON column behavior for structs, maps and slices which would otherwise be
+// handed to the driver as is (or as Postgres arrays in the case of slices).
+//
+// Scan decodes the column with JSONUnmarshal and Value encodes it with
+// JSONMarshal. When JSON is itself encoded as JSON it is transparent: the
+// wrapped value is written without a surrounding object.
+type JSON[T any] struct {
+	V T
+}
+
+// JSONFrom wraps v.
+func JSONFrom[T any](v T) JSON[T] {
+	return JSON[T]{V: v}
+}
+
+// Scan implements the sql.Scanner interface. A NULL column leaves the zero
+// value, wrap JSON in a null.Val to tell NULL apart from the JSON null.
+func (j *JSON[T]) Scan(value any) error {
+	var zero T
+	j.V = zero
+
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return JSONUnmarshal(value, &j.V)
+	case string:
+		return JSONUnmarshal([]byte(value), &j.V)
+	}
+	return fmt.Errorf("cannot scan %T into a JSON column", value)
+}
+
+// Value implements the driver.Valuer interface. The JSON is returned as a
+// string rather than []byte so that drivers do not send it as binary data.
+func (j JSON[T]) Value() (driver.Value, error) {
+	b, err := JSONMarshal(j.V)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (j JSON[T]) MarshalJSON() ([]byte, error) {
+	return JSONMarshal(j.V)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (j *JSON[T]) UnmarshalJSON(data []byte) error {
+	return JSONUnmarshal(data, &j.V)
+}
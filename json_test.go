@@ -0,0 +1,107 @@
+package opt
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+type jsonSettings struct {
+	Theme string         `json:"theme"`
+	Flags map[string]int `json:"flags,omitempty"`
+}
+
+// Compile time interface checks
+var (
+	_ sql.Scanner   = &JSON[jsonSettings]{}
+	_ driver.Valuer = JSON[jsonSettings]{}
+)
+
+func TestJSONScan(t *testing.T) {
+	t.Parallel()
+
+	var j JSON[jsonSettings]
+	if err := j.Scan([]byte(`{"theme":"dark","flags":{"a":1}}`)); err != nil {
+		t.Fatal(err)
+	}
+	want := jsonSettings{Theme: "dark", Flags: map[string]int{"a": 1}}
+	if !reflect.DeepEqual(j.V, want) {
+		t.Errorf("want: %#v got: %#v", want, j.V)
+	}
+
+	if err := j.Scan(`{"theme":"light"}`); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(j.V, jsonSettings{Theme: "light"}) {
+		t.Error("old value should be cleared before scanning:", j.V)
+	}
+
+	if err := j.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(j.V, jsonSettings{}) {
+		t.Error("nil should leave the zero value:", j.V)
+	}
+
+	if err := j.Scan(int64(5)); err == nil {
+		t.Error("expected an error scanning an int64")
+	}
+	if err := j.Scan("{"); err == nil {
+		t.Error("expected an error for bad json")
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	t.Parallel()
+
+	v, err := JSONFrom([]int{1, 2}).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "[1,2]" {
+		t.Errorf("want: [1,2] got: %#v", v)
+	}
+
+	v, err = ToDriverValue(JSONFrom(map[string]bool{"a": true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `{"a":true}` {
+		t.Errorf(`want: {"a":true} got: %#v`, v)
+	}
+}
+
+func TestJSONConvertAssign(t *testing.T) {
+	t.Parallel()
+
+	var j JSON[[]string]
+	if err := ConvertAssign(&j, []byte(`["a","b"]`)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(j.V, []string{"a", "b"}) {
+		t.Error("wrong value:", j.V)
+	}
+}
+
+func TestJSONMarshal(t *testing.T) {
+	t.Parallel()
+
+	b, err := JSONMarshal(struct {
+		S JSON[jsonSettings] `json:"s"`
+	}{JSONFrom(jsonSettings{Theme: "x"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"s":{"theme":"x"}}` {
+		t.Error("wrong json:", string(b))
+	}
+
+	var j JSON[jsonSettings]
+	if err := JSONUnmarshal([]byte(`{"theme":"y"}`), &j); err != nil {
+		t.Fatal(err)
+	}
+	if j.V.Theme != "y" {
+		t.Error("wrong value:", j.V)
+	}
+}
@@ -0,0 +1,143 @@
+// Package jsonpatch converts structs of omit, null and omitnull values into
+// RFC 6902 JSON Patch documents.
+//
+// Each field is turned into at most one operation. The path is derived from
+// the field's json tag and the operation used is controlled by the jsonpatch
+// tag:
+//
+//	type UserPatch struct {
+//		// set: replace, null: replace with a null value
+//		Name omitnull.Val[string] `json:"name"`
+//		// set: add, null: remove
+//		Nick omitnull.Val[string] `json:"nick" jsonpatch:"add,remove"`
+//	}
+//
+// Unset fields never produce an operation.
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// TagName is the struct tag used to select operations for a field.
+const TagName = "jsonpatch"
+
+// Operation names from RFC 6902 that this package produces
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Operation is a single JSON Patch operation.
+type Operation struct {
+	Op    string
+	Path  string
+	Value any
+}
+
+// MarshalJSON implements json.Marshaler. The value member is written for every
+// operation except remove, even when it is null.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	if o.Op == OpRemove {
+		return opt.JSONMarshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+
+	return opt.JSONMarshal(struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	}{o.Op, o.Path, o.Value})
+}
+
+// EscapePath escapes a single JSON Pointer (RFC 6901) reference token.
+func EscapePath(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
+
+// Operations creates the list of operations described by the struct patch.
+// Set fields become an add or replace of the value, null fields become a
+// replace with null or a remove depending on the jsonpatch tag. The default
+// for both is replace.
+//
+// The order of the operations follows the order of the struct's fields.
+func Operations(patch any) ([]Operation, error) {
+	rv := reflect.Indirect(reflect.ValueOf(patch))
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New("jsonpatch: patch must be a struct or pointer to a struct")
+	}
+
+	var ops []Operation
+	for _, f := range optreflect.Fields(rv.Type(), "json") {
+		if optreflect.KindOf(f.Type) == optreflect.KindNone {
+			continue
+		}
+
+		setOp, nullOp, err := fieldOps(f.Tag.Get(TagName))
+		if err != nil {
+			return nil, fmt.Errorf("jsonpatch: field %s: %w", f.Name, err)
+		}
+
+		field, ok := f.Value(rv)
+		if !ok {
+			continue
+		}
+
+		path := "/" + EscapePath(f.Name)
+		switch state, value := optreflect.Get(field); state {
+		case optreflect.StateUnset:
+			continue
+		case optreflect.StateNull:
+			ops = append(ops, Operation{Op: nullOp, Path: path})
+		default:
+			ops = append(ops, Operation{Op: setOp, Path: path, Value: value.Interface()})
+		}
+	}
+
+	return ops, nil
+}
+
+// Marshal is a shortcut for marshaling the result of Operations.
+func Marshal(patch any) ([]byte, error) {
+	ops, err := Operations(patch)
+	if err != nil {
+		return nil, err
+	}
+	if ops == nil {
+		ops = []Operation{}
+	}
+	return opt.JSONMarshal(ops)
+}
+
+// fieldOps parses the jsonpatch tag to find the operations to use for set
+// and null values.
+func fieldOps(tag string) (setOp, nullOp string, err error) {
+	setOp, nullOp = OpReplace, OpReplace
+	if len(tag) == 0 {
+		return setOp, nullOp, nil
+	}
+
+	for _, op := range strings.Split(tag, ",") {
+		switch op {
+		case OpAdd:
+			setOp = OpAdd
+		case OpRemove:
+			nullOp = OpRemove
+		case OpReplace:
+		default:
+			return "", "", fmt.Errorf("unsupported operation %q in tag", op)
+		}
+	}
+
+	return setOp, nullOp, nil
+}
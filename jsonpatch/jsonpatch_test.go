@@ -0,0 +1,112 @@
+package jsonpatch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type userPatch struct {
+	Name    omitnull.Val[string] `json:"name"`
+	Nick    omitnull.Val[string] `json:"nick" jsonpatch:"add,remove"`
+	Age     omit.Val[int]        `json:"age" jsonpatch:"add"`
+	Email   null.Val[string]     `json:"e/mail~"`
+	Unset   omitnull.Val[int]    `json:"unset"`
+	Ignored omitnull.Val[int]    `json:"-"`
+	ID      int
+}
+
+func TestOperations(t *testing.T) {
+	t.Parallel()
+
+	p := userPatch{
+		Name:    omitnull.FromPtr[string](nil),
+		Nick:    omitnull.FromPtr[string](nil),
+		Age:     omit.From(5),
+		Ignored: omitnull.From(1),
+		ID:      1,
+	}
+
+	ops, err := Operations(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Operation{
+		{Op: OpReplace, Path: "/name"},
+		{Op: OpRemove, Path: "/nick"},
+		{Op: OpAdd, Path: "/age", Value: 5},
+		{Op: OpReplace, Path: "/e~1mail~0"},
+	}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("operations wrong\nwant: %#v\ngot:  %#v", want, ops)
+	}
+
+	p.Name.Set("bob")
+	p.Nick.Set("bobby")
+	p.Email.Set("bob@example.com")
+	b, err := Marshal(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := `[{"op":"replace","path":"/name","value":"bob"},` +
+		`{"op":"add","path":"/nick","value":"bobby"},` +
+		`{"op":"add","path":"/age","value":5},` +
+		`{"op":"replace","path":"/e~1mail~0","value":"bob@example.com"}]`
+	if string(b) != wantJSON {
+		t.Errorf("json wrong\nwant: %s\ngot:  %s", wantJSON, b)
+	}
+}
+
+func TestMarshalNullAndRemove(t *testing.T) {
+	t.Parallel()
+
+	b, err := Marshal(struct {
+		A omitnull.Val[int] `json:"a"`
+		B omitnull.Val[int] `json:"b" jsonpatch:"remove"`
+		C omitnull.Val[int] `json:"c"`
+	}{omitnull.FromPtr[int](nil), omitnull.FromPtr[int](nil), omitnull.Val[int]{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `[{"op":"replace","path":"/a","value":null},{"op":"remove","path":"/b"}]` {
+		t.Error("json wrong:", string(b))
+	}
+
+	b, err = Marshal(struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `[]` {
+		t.Error("json wrong:", string(b))
+	}
+}
+
+func TestOperationsErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Operations(5); err == nil {
+		t.Error("expected an error for a non-struct")
+	}
+
+	_, err := Operations(struct {
+		A omitnull.Val[int] `jsonpatch:"move"`
+	}{})
+	if err == nil {
+		t.Error("expected an error for a bad tag")
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	t.Parallel()
+
+	if got := EscapePath("a/b~c"); got != "a~1b~0c" {
+		t.Error("escaped wrong:", got)
+	}
+	if got := EscapePath("~1"); got != "~01" {
+		t.Error("escaped wrong:", got)
+	}
+}
@@ -0,0 +1,221 @@
+// Package mergepatch implements RFC 7396 JSON Merge Patch on top of the
+// omit, null and omitnull types.
+//
+// The three states of omitnull.Val map directly onto a merge patch: a member
+// that is missing from the document leaves the target alone (unset), a member
+// that is null removes it from the target (null) and any other value replaces
+// it (set). Nested objects are patched recursively, which is modeled with an
+// omitnull.Val whose T is another patch struct:
+//
+//	type AddressPatch struct {
+//		City omitnull.Val[string] `json:"city,omitzero"`
+//	}
+//
+//	type UserPatch struct {
+//		Name    omitnull.Val[string]       `json:"name,omitzero"`
+//		Address omitnull.Val[AddressPatch] `json:"address,omitzero"`
+//	}
+package mergepatch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/globaldata"
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// ErrNotObject is returned when a merge patch document must be an object but
+// is not.
+var ErrNotObject = errors.New("mergepatch: document is not an object")
+
+// Decode a merge patch document into v which must be a pointer to a struct
+// of omit, null and omitnull values. v is reset to its zero value before
+// decoding so every member that is not in the document ends up unset.
+func Decode(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("mergepatch: decode target must be a non-nil pointer to a struct")
+	}
+	if !isObject(data) {
+		return ErrNotObject
+	}
+
+	rv.Elem().SetZero()
+	return opt.JSONUnmarshal(data, v)
+}
+
+// Merge implements the MergePatch algorithm from RFC 7396 on values that have
+// been decoded by encoding/json into any (map[string]any, []any, string,
+// float64, bool and nil).
+//
+// Neither target nor patch are modified, objects in the result that had to be
+// changed are copies.
+func Merge(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if ok {
+		targetObj = maps.Clone(targetObj)
+	} else {
+		targetObj = make(map[string]any, len(patchObj))
+	}
+
+	for name, value := range patchObj {
+		if value == nil {
+			delete(targetObj, name)
+			continue
+		}
+		targetObj[name] = Merge(targetObj[name], value)
+	}
+
+	return targetObj
+}
+
+// Apply the merge patch document to the value pointed to by target.
+//
+// When target is a *map[string]any the document must be an object. When
+// target is an *any it is replaced by the result of Merge. Any other target
+// is round-tripped through JSON using opt.JSONMarshal and opt.JSONUnmarshal,
+// so struct targets are patched according to their json tags and are set to
+// a freshly decoded value with the result.
+func Apply(target any, doc []byte) error {
+	var patch any
+	if err := opt.JSONUnmarshal(doc, &patch); err != nil {
+		return err
+	}
+
+	switch t := target.(type) {
+	case *map[string]any:
+		if _, ok := patch.(map[string]any); !ok {
+			return ErrNotObject
+		}
+		*t = Merge(*t, patch).(map[string]any)
+		return nil
+	case *any:
+		*t = Merge(*t, patch)
+		return nil
+	}
+
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("mergepatch: apply target must be a non-nil pointer")
+	}
+
+	b, err := opt.JSONMarshal(target)
+	if err != nil {
+		return err
+	}
+	var current any
+	if err := opt.JSONUnmarshal(b, &current); err != nil {
+		return err
+	}
+
+	b, err = opt.JSONMarshal(Merge(current, patch))
+	if err != nil {
+		return err
+	}
+	fresh := reflect.New(rv.Type().Elem())
+	if err := opt.JSONUnmarshal(b, fresh.Interface()); err != nil {
+		return err
+	}
+
+	rv.Elem().Set(fresh.Elem())
+	return nil
+}
+
+// Generate creates a merge patch document from a struct of omit, null and
+// omitnull values. Fields whose IsZero method reports true (unset) are left
+// out of the document, null fields become null and set fields are encoded
+// with opt.JSONMarshal. A set value that is itself a struct containing Val
+// fields is generated recursively so its unset fields are left out as well.
+//
+// Fields that are not one of the Val types are always included unless their
+// tag has omitempty or omitzero and they are the zero value. Field names come
+// from the json tag.
+func Generate(patch any) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(patch))
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New("mergepatch: patch must be a struct or pointer to a struct")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := generate(buf, rv); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+func generate(buf *bytes.Buffer, rv reflect.Value) error {
+	buf.WriteByte('{')
+	first := true
+	for _, f := range optreflect.Fields(rv.Type(), "json") {
+		field, ok := f.Value(rv)
+		if !ok {
+			continue
+		}
+
+		var value reflect.Value
+		null := false
+		if optreflect.KindOf(f.Type) != optreflect.KindNone {
+			if field.Interface().(isZeroer).IsZero() {
+				continue
+			}
+			var state optreflect.State
+			state, value = optreflect.Get(field)
+			null = state != optreflect.StateSet
+		} else {
+			if (f.HasOption("omitempty") || f.HasOption("omitzero")) && field.IsZero() {
+				continue
+			}
+			value = field
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		name, err := opt.JSONMarshal(f.Name)
+		if err != nil {
+			return err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+
+		switch {
+		case null:
+			buf.Write(globaldata.JSONNull)
+		case optreflect.HasVals(value.Type(), "json"):
+			if err := generate(buf, value); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		default:
+			b, err := opt.JSONMarshal(value.Interface())
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+			buf.Write(b)
+		}
+	}
+	buf.WriteByte('}')
+
+	return nil
+}
+
+// isObject checks if the JSON document is an object without decoding it.
+func isObject(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) != 0 && data[0] == '{'
+}
@@ -0,0 +1,212 @@
+package mergepatch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+// rfcExamples are the test cases from RFC 7396 Appendix A
+var rfcExamples = []struct {
+	Original string
+	Patch    string
+	Result   string
+}{
+	{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+	{`{"a":"b"}`, `{"a":null}`, `{}`},
+	{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+	{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+	{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+	{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+	{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+	{`{"a":"b"}`, `["c"]`, `["c"]`},
+	{`{"a":"foo"}`, `null`, `null`},
+	{`{"a":"foo"}`, `"bar"`, `"bar"`},
+	{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+	{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+	{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+}
+
+func TestRFCExamples(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range rfcExamples {
+		t.Run(test.Original+test.Patch, func(t *testing.T) {
+			var original, want any
+			mustUnmarshal(t, test.Original, &original)
+			mustUnmarshal(t, test.Result, &want)
+
+			var patch any
+			mustUnmarshal(t, test.Patch, &patch)
+			if got := Merge(original, patch); !reflect.DeepEqual(got, want) {
+				t.Errorf("Merge wrong\nwant: %#v\ngot:  %#v", want, got)
+			}
+
+			target := original
+			if err := Apply(&target, []byte(test.Patch)); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(target, want) {
+				t.Errorf("Apply wrong\nwant: %#v\ngot:  %#v", want, target)
+			}
+		})
+	}
+}
+
+func TestMergeDoesNotModify(t *testing.T) {
+	t.Parallel()
+
+	target := map[string]any{"a": map[string]any{"b": "c"}}
+	patch := map[string]any{"a": map[string]any{"b": nil}}
+	_ = Merge(target, patch)
+
+	if target["a"].(map[string]any)["b"] != "c" {
+		t.Error("target was modified")
+	}
+}
+
+type addressPatch struct {
+	City omitnull.Val[string] `json:"city,omitzero"`
+	Zip  omitnull.Val[string] `json:"zip,omitzero"`
+}
+
+type userPatch struct {
+	Name    omit.Val[string]           `json:"name,omitzero"`
+	Age     omitnull.Val[int]          `json:"age,omitzero"`
+	Address omitnull.Val[addressPatch] `json:"address,omitzero"`
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	p := userPatch{Name: omit.From("stale")}
+	err := Decode([]byte(`{"age":null,"address":{"city":"Toronto","zip":null}}`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Name.IsUnset() {
+		t.Error("name should be unset")
+	}
+	if !p.Age.IsNull() {
+		t.Error("age should be null")
+	}
+	addr := p.Address.MustGet()
+	if addr.City.MustGet() != "Toronto" {
+		t.Error("city wrong")
+	}
+	if !addr.Zip.IsNull() {
+		t.Error("zip should be null")
+	}
+
+	if err := Decode([]byte(` ["a"]`), &p); !errors.Is(err, ErrNotObject) {
+		t.Error("expected not object error, got:", err)
+	}
+	if err := Decode([]byte(`{}`), p); err == nil {
+		t.Error("expected an error for non-pointer")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	p := userPatch{
+		Age:     omitnull.FromPtr[int](nil),
+		Address: omitnull.From(addressPatch{City: omitnull.From("Toronto")}),
+	}
+	b, err := Generate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"age":null,"address":{"city":"Toronto"}}` {
+		t.Error("generated wrong:", string(b))
+	}
+
+	// Round trip it through decode
+	var decoded userPatch
+	if err := Decode(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Name.IsUnset() || !decoded.Age.IsNull() ||
+		decoded.Address.MustGet().City.MustGet() != "Toronto" ||
+		!decoded.Address.MustGet().Zip.IsUnset() {
+		t.Errorf("round trip wrong: %#v", decoded)
+	}
+
+	b, err = Generate(&userPatch{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{}` {
+		t.Error("generated wrong:", string(b))
+	}
+
+	plain := struct {
+		A int `json:"a"`
+		B int `json:"b,omitempty"`
+		C int `json:"-"`
+	}{}
+	b, err = Generate(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":0}` {
+		t.Error("generated wrong:", string(b))
+	}
+}
+
+type user struct {
+	Name    string   `json:"name"`
+	Age     *int     `json:"age"`
+	Tags    []string `json:"tags"`
+	Address struct {
+		City string `json:"city"`
+		Zip  string `json:"zip"`
+	} `json:"address"`
+}
+
+func TestApplyStruct(t *testing.T) {
+	t.Parallel()
+
+	age := 5
+	u := user{Name: "a", Age: &age, Tags: []string{"x"}}
+	u.Address.City = "Ottawa"
+	u.Address.Zip = "K1A"
+
+	err := Apply(&u, []byte(`{"age":null,"tags":["y"],"address":{"city":"Toronto"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "a" || u.Age != nil || u.Tags[0] != "y" ||
+		u.Address.City != "Toronto" || u.Address.Zip != "K1A" {
+		t.Errorf("apply wrong: %#v", u)
+	}
+
+	m := map[string]any{"a": "b"}
+	if err := Apply(&m, []byte(`{"a":null,"c":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, map[string]any{"c": float64(1)}) {
+		t.Errorf("apply wrong: %#v", m)
+	}
+	if err := Apply(&m, []byte(`[1]`)); !errors.Is(err, ErrNotObject) {
+		t.Error("expected not object error, got:", err)
+	}
+	if err := Apply(u, []byte(`{}`)); err == nil {
+		t.Error("expected an error for non-pointer")
+	}
+}
+
+func mustUnmarshal(t *testing.T, s string, v any) {
+	t.Helper()
+
+	if err := opt.JSONUnmarshal([]byte(s), v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,770 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+type decoder struct {
+	data  []byte
+	off   int
+	depth int
+}
+
+// nest is called before descending into an array or map, the returned
+// function must be called once it is done.
+func (d *decoder) nest() (func(), error) {
+	if d.depth >= MaxDepth {
+		return nil, fmt.Errorf("%w of %d", ErrMaxDepth, MaxDepth)
+	}
+	d.depth++
+	return func() { d.depth-- }, nil
+}
+
+func (d *decoder) peek() (byte, error) {
+	if d.off >= len(d.data) {
+		return 0, fmt.Errorf("%w: unexpected end of data", ErrSyntax)
+	}
+	return d.data[d.off], nil
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.off {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrSyntax)
+	}
+	b := d.data[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// uintN reads a big endian unsigned integer of size bytes.
+func (d *decoder) uintN(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// integer reads any of the integer formats. Negative numbers are returned in
+// n with neg set, otherwise the number is in u.
+func (d *decoder) integer() (n int64, u uint64, neg bool, err error) {
+	b, err := d.peek()
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		d.off++
+		return 0, uint64(b), false, nil
+	case b >= negFix:
+		d.off++
+		return int64(int8(b)), 0, true, nil
+	case b >= formatUint8 && b <= formatUint64:
+		d.off++
+		u, err = d.uintN(1 << (b - formatUint8))
+		return 0, u, false, err
+	case b >= formatInt8 && b <= formatInt64:
+		d.off++
+		size := 1 << (b - formatInt8)
+		u, err = d.uintN(size)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		switch size {
+		case 1:
+			n = int64(int8(u))
+		case 2:
+			n = int64(int16(u))
+		case 4:
+			n = int64(int32(u))
+		default:
+			n = int64(u)
+		}
+		if n >= 0 {
+			return 0, uint64(n), false, nil
+		}
+		return n, 0, true, nil
+	}
+
+	return 0, 0, false, errNotInt
+}
+
+var errNotInt = fmt.Errorf("%w: not an integer", ErrTypeMismatch)
+
+func (d *decoder) int64() (int64, error) {
+	n, u, neg, err := d.integer()
+	switch {
+	case err != nil:
+		return 0, err
+	case neg:
+		return n, nil
+	case u > math.MaxInt64:
+		return 0, fmt.Errorf("%w: %d overflows int64", ErrTypeMismatch, u)
+	}
+	return int64(u), nil
+}
+
+func (d *decoder) uint64() (uint64, error) {
+	n, u, neg, err := d.integer()
+	switch {
+	case err != nil:
+		return 0, err
+	case neg:
+		return 0, fmt.Errorf("%w: %d overflows uint64", ErrTypeMismatch, n)
+	}
+	return u, nil
+}
+
+// float reads either float format or an integer.
+func (d *decoder) float() (float64, error) {
+	b, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	switch b {
+	case formatFloat32:
+		d.off++
+		bits, err := d.uintN(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case formatFloat64:
+		d.off++
+		bits, err := d.uintN(8)
+		return math.Float64frombits(bits), err
+	}
+
+	n, u, neg, err := d.integer()
+	if err == errNotInt {
+		return 0, fmt.Errorf("%w: not a float", ErrTypeMismatch)
+	} else if err != nil {
+		return 0, err
+	} else if neg {
+		return float64(n), nil
+	}
+	return float64(u), nil
+}
+
+func (d *decoder) bool() (bool, error) {
+	b, err := d.peek()
+	if err != nil {
+		return false, err
+	}
+	if b != formatTrue && b != formatFalse {
+		return false, fmt.Errorf("%w: not a bool", ErrTypeMismatch)
+	}
+	d.off++
+	return b == formatTrue, nil
+}
+
+// raw reads a str or bin value, the str formats are only accepted if str is
+// true and the bin formats if bin is true.
+func (d *decoder) raw(str, bin bool) ([]byte, error) {
+	b, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	var n uint64
+	switch {
+	case str && b >= fixStr && b <= fixStr|0x1f:
+		d.off++
+		n = uint64(b & 0x1f)
+	case str && b >= formatStr8 && b <= formatStr32:
+		d.off++
+		n, err = d.uintN(1 << (b - formatStr8))
+	case bin && b >= formatBin8 && b <= formatBin32:
+		d.off++
+		n, err = d.uintN(1 << (b - formatBin8))
+	default:
+		return nil, fmt.Errorf("%w: not a string", ErrTypeMismatch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)-d.off) {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrSyntax)
+	}
+	return d.read(int(n))
+}
+
+// extType peeks at the type of the extension value that starts at the current
+// offset, the caller has to check that it is one.
+func (d *decoder) extType() byte {
+	i := d.off + 1
+	if d.data[d.off] >= formatExt8 && d.data[d.off] <= formatExt32 {
+		i += 1 << (d.data[d.off] - formatExt8)
+	}
+	if i >= len(d.data) {
+		return 0
+	}
+	return d.data[i]
+}
+
+// time reads a value of the timestamp extension type in any of its formats.
+func (d *decoder) time() (time.Time, error) {
+	b, err := d.peek()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var n uint64
+	switch b {
+	case formatFixExt4, formatFixExt8:
+		d.off++
+		n = 1 << (b - formatFixExt1)
+	case formatExt8:
+		d.off++
+		n, err = d.uintN(1)
+		if err != nil {
+			return time.Time{}, err
+		}
+	default:
+		return time.Time{}, fmt.Errorf("%w: not a timestamp", ErrTypeMismatch)
+	}
+
+	ext, err := d.read(1)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ext[0] != extTimestamp {
+		return time.Time{}, fmt.Errorf("%w: extension type %d is not a timestamp", ErrTypeMismatch, int8(ext[0]))
+	}
+	data, err := d.read(int(n))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var sec int64
+	var nsec uint32
+	switch n {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		x := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(x&(1<<34-1)), uint32(x>>34)
+	case 12:
+		nsec = binary.BigEndian.Uint32(data)
+		sec = int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return time.Time{}, fmt.Errorf("%w: timestamp of %d bytes", ErrSyntax, n)
+	}
+	if nsec > 999999999 {
+		return time.Time{}, fmt.Errorf("%w: timestamp nanoseconds out of range", ErrSyntax)
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), nil
+}
+
+// container reads the header of an array (or map if isMap) and returns the
+// number of elements. Each element takes at least one byte so lengths longer
+// than the remaining data are rejected before anything is allocated.
+func (d *decoder) container(isMap bool) (int, error) {
+	b, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	fixed, f16, f32 := fixArray, formatArray16, formatArray32
+	if isMap {
+		fixed, f16, f32 = fixMap, formatMap16, formatMap32
+	}
+
+	var n uint64
+	switch {
+	case b&0xf0 == fixed:
+		d.off++
+		n = uint64(b & 0x0f)
+	case b == f16:
+		d.off++
+		n, err = d.uintN(2)
+	case b == f32:
+		d.off++
+		n, err = d.uintN(4)
+	default:
+		if isMap {
+			return 0, fmt.Errorf("%w: not a map", ErrTypeMismatch)
+		}
+		return 0, fmt.Errorf("%w: not an array", ErrTypeMismatch)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data)-d.off) {
+		return 0, fmt.Errorf("%w: length %d exceeds data", ErrSyntax, n)
+	}
+	return int(n), nil
+}
+
+func (d *decoder) decode(v reflect.Value) error {
+	b, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	if kind := optreflect.KindOf(v.Type()); kind != optreflect.KindNone {
+		return d.decodeVal(v, kind, b)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if b == formatNil {
+			d.off++
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		x, err := d.value()
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	}
+
+	if b == formatNil {
+		// like encoding/json nil leaves other types untouched
+		d.off++
+		return nil
+	}
+
+	return d.decodeKind(v)
+}
+
+func (d *decoder) decodeKind(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := d.bool()
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := d.int64()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%w: %d overflows %s", ErrTypeMismatch, n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := d.uint64()
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("%w: %d overflows %s", ErrTypeMismatch, n, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := d.float()
+		if err != nil {
+			return err
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("%w: %g overflows %s", ErrTypeMismatch, f, v.Type())
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, err := d.raw(true, false)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(s))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.raw(true, true)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		return d.array(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.raw(true, true)
+			if err != nil {
+				return err
+			}
+			if len(b) != v.Len() {
+				return fmt.Errorf("%w: %d bytes into %s", ErrTypeMismatch, len(b), v.Type())
+			}
+			for i, c := range b {
+				v.Index(i).SetUint(uint64(c))
+			}
+			return nil
+		}
+		return d.array(v)
+	case reflect.Map:
+		return d.mapping(v)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			t, err := d.time()
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(t))
+			return nil
+		}
+		if optreflect.Opaque(v.Type(), TagName) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		return d.structure(v)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+
+	return nil
+}
+
+// decodeVal decodes into one of the Val types, b is the first byte of the
+// value.
+func (d *decoder) decodeVal(v reflect.Value, kind optreflect.Kind, b byte) error {
+	if b == formatNil {
+		if !kind.CanNull() {
+			return fmt.Errorf("%w: cannot decode nil into %s", ErrTypeMismatch, v.Type())
+		}
+		d.off++
+		optreflect.SetNull(v)
+		return nil
+	}
+
+	if ok, err := d.fast(v.Addr().Interface()); ok {
+		return err
+	}
+
+	elem := reflect.New(optreflect.ElemType(v.Type())).Elem()
+	if err := d.decode(elem); err != nil {
+		return err
+	}
+	optreflect.Set(v, elem)
+	return nil
+}
+
+// fast decodes into Vals of the primitive types without going through
+// reflection, returning false if x is not one of them.
+func (d *decoder) fast(x any) (bool, error) {
+	switch x := x.(type) {
+	case interface{ Set(bool) }:
+		return true, fastSet(x, d.bool)
+	case interface{ Set(int) }:
+		return true, fastSet(x, signed[int](d))
+	case interface{ Set(int8) }:
+		return true, fastSet(x, signed[int8](d))
+	case interface{ Set(int16) }:
+		return true, fastSet(x, signed[int16](d))
+	case interface{ Set(int32) }:
+		return true, fastSet(x, signed[int32](d))
+	case interface{ Set(int64) }:
+		return true, fastSet(x, d.int64)
+	case interface{ Set(uint) }:
+		return true, fastSet(x, unsigned[uint](d))
+	case interface{ Set(uint8) }:
+		return true, fastSet(x, unsigned[uint8](d))
+	case interface{ Set(uint16) }:
+		return true, fastSet(x, unsigned[uint16](d))
+	case interface{ Set(uint32) }:
+		return true, fastSet(x, unsigned[uint32](d))
+	case interface{ Set(uint64) }:
+		return true, fastSet(x, d.uint64)
+	case interface{ Set(float32) }:
+		return true, fastSet(x, func() (float32, error) {
+			f, err := d.float()
+			if err == nil && (f > math.MaxFloat32 || f < -math.MaxFloat32) && !math.IsInf(f, 0) {
+				return 0, fmt.Errorf("%w: %g overflows float32", ErrTypeMismatch, f)
+			}
+			return float32(f), err
+		})
+	case interface{ Set(float64) }:
+		return true, fastSet(x, d.float)
+	case interface{ Set(string) }:
+		return true, fastSet(x, func() (string, error) {
+			s, err := d.raw(true, false)
+			return string(s), err
+		})
+	case interface{ Set([]byte) }:
+		return true, fastSet(x, func() ([]byte, error) {
+			b, err := d.raw(true, true)
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte{}, b...), nil
+		})
+	}
+	return false, nil
+}
+
+func fastSet[T any](x interface{ Set(T) }, read func() (T, error)) error {
+	val, err := read()
+	if err != nil {
+		return err
+	}
+	x.Set(val)
+	return nil
+}
+
+func signed[T int | int8 | int16 | int32](d *decoder) func() (T, error) {
+	return func() (T, error) {
+		n, err := d.int64()
+		if err != nil {
+			return 0, err
+		}
+		if int64(T(n)) != n {
+			return 0, fmt.Errorf("%w: %d overflows %T", ErrTypeMismatch, n, T(0))
+		}
+		return T(n), nil
+	}
+}
+
+func unsigned[T uint | uint8 | uint16 | uint32](d *decoder) func() (T, error) {
+	return func() (T, error) {
+		n, err := d.uint64()
+		if err != nil {
+			return 0, err
+		}
+		if uint64(T(n)) != n {
+			return 0, fmt.Errorf("%w: %d overflows %T", ErrTypeMismatch, n, T(0))
+		}
+		return T(n), nil
+	}
+}
+
+func (d *decoder) array(v reflect.Value) error {
+	done, err := d.nest()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	n, err := d.container(false)
+	if err != nil {
+		return err
+	}
+
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+	}
+	for i := range n {
+		if i >= v.Len() {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	for i := n; i < v.Len(); i++ {
+		v.Index(i).SetZero()
+	}
+	return nil
+}
+
+func (d *decoder) mapping(v reflect.Value) error {
+	done, err := d.nest()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	n, err := d.container(true)
+	if err != nil {
+		return err
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), n))
+	}
+	for range n {
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := d.decode(key); err != nil {
+			return err
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decode(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+// structure decodes a map into the fields of a struct by their key, keys that
+// do not match a field are skipped.
+func (d *decoder) structure(v reflect.Value) error {
+	done, err := d.nest()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	n, err := d.container(true)
+	if err != nil {
+		return err
+	}
+
+	fields := optreflect.Fields(v.Type(), TagName)
+	for range n {
+		key, err := d.raw(true, false)
+		if err != nil {
+			return err
+		}
+
+		f, ok := optreflect.FieldByName(fields, string(key))
+		if !ok {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(f.Alloc(v)); err != nil {
+			return fmt.Errorf("%w (field %s)", err, f.Name)
+		}
+	}
+	return nil
+}
+
+// value decodes the next value into the natural Go type for it: int64 (or
+// uint64 when it does not fit), float64, bool, string, []byte, []any,
+// map[string]any or nil.
+func (d *decoder) value() (any, error) {
+	b, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b == formatNil:
+		d.off++
+		return nil, nil
+	case b == formatTrue || b == formatFalse:
+		return d.bool()
+	case b == formatFloat32 || b == formatFloat64:
+		return d.float()
+	case b <= 0x7f || b >= negFix || (b >= formatUint8 && b <= formatInt64):
+		n, u, neg, err := d.integer()
+		switch {
+		case err != nil:
+			return nil, err
+		case neg:
+			return n, nil
+		case u > math.MaxInt64:
+			return u, nil
+		}
+		return int64(u), nil
+	case b >= fixStr && b <= fixStr|0x1f, b >= formatStr8 && b <= formatStr32:
+		s, err := d.raw(true, false)
+		return string(s), err
+	case b >= formatBin8 && b <= formatBin32:
+		raw, err := d.raw(false, true)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, raw...), nil
+	case b&0xf0 == fixArray || b == formatArray16 || b == formatArray32:
+		var out []any
+		if err := d.array(reflect.ValueOf(&out).Elem()); err != nil {
+			return nil, err
+		}
+		return out, nil
+	case b == formatFixExt4 || b == formatFixExt8 || b == formatExt8:
+		if d.extType() == extTimestamp {
+			return d.time()
+		}
+	case b&0xf0 == fixMap || b == formatMap16 || b == formatMap32:
+		out := map[string]any{}
+		if err := d.mapping(reflect.ValueOf(out)); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	return nil, fmt.Errorf("%w: format 0x%02x", ErrUnsupportedType, b)
+}
+
+// skip consumes the next value without decoding it.
+func (d *decoder) skip() error {
+	b, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case b <= 0x7f, b >= negFix, b == formatNil, b == formatTrue, b == formatFalse:
+		d.off++
+		return nil
+	case b >= formatUint8 && b <= formatInt64, b == formatFloat32, b == formatFloat64:
+		_, err := d.value()
+		return err
+	case b >= fixStr && b <= fixStr|0x1f, b >= formatStr8 && b <= formatStr32:
+		_, err := d.raw(true, false)
+		return err
+	case b >= formatBin8 && b <= formatBin32:
+		_, err := d.raw(false, true)
+		return err
+	case b >= formatFixExt1 && b <= formatFixExt16:
+		d.off++
+		_, err := d.read(1 + 1<<(b-formatFixExt1))
+		return err
+	case b >= formatExt8 && b <= formatExt32:
+		d.off++
+		n, err := d.uintN(1 << (b - formatExt8))
+		if err != nil {
+			return err
+		}
+		if n > uint64(len(d.data)-d.off) {
+			return fmt.Errorf("%w: unexpected end of data", ErrSyntax)
+		}
+		_, err = d.read(1 + int(n))
+		return err
+	}
+
+	done, err := d.nest()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	isMap := b&0xf0 == fixMap || b == formatMap16 || b == formatMap32
+	n, err := d.container(isMap)
+	if err != nil {
+		return fmt.Errorf("%w: unknown format 0x%02x", ErrSyntax, b)
+	}
+	if isMap {
+		n *= 2
+	}
+	for range n {
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// Format bytes
+const (
+	formatNil      byte = 0xc0
+	formatFalse    byte = 0xc2
+	formatTrue     byte = 0xc3
+	formatBin8     byte = 0xc4
+	formatBin16    byte = 0xc5
+	formatBin32    byte = 0xc6
+	formatExt8     byte = 0xc7
+	formatExt16    byte = 0xc8
+	formatExt32    byte = 0xc9
+	formatFloat32  byte = 0xca
+	formatFloat64  byte = 0xcb
+	formatUint8    byte = 0xcc
+	formatUint16   byte = 0xcd
+	formatUint32   byte = 0xce
+	formatUint64   byte = 0xcf
+	formatInt8     byte = 0xd0
+	formatInt16    byte = 0xd1
+	formatInt32    byte = 0xd2
+	formatInt64    byte = 0xd3
+	formatFixExt1  byte = 0xd4
+	formatFixExt2  byte = 0xd5
+	formatFixExt4  byte = 0xd6
+	formatFixExt8  byte = 0xd7
+	formatFixExt16 byte = 0xd8
+	formatStr8     byte = 0xd9
+	formatStr16    byte = 0xda
+	formatStr32    byte = 0xdb
+	formatArray16  byte = 0xdc
+	formatArray32  byte = 0xdd
+	formatMap16    byte = 0xde
+	formatMap32    byte = 0xdf
+
+	fixMap   byte = 0x80
+	fixArray byte = 0x90
+	fixStr   byte = 0xa0
+	negFix   byte = 0xe0
+)
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.nil()
+		return nil
+	}
+
+	if optreflect.KindOf(v.Type()) != optreflect.KindNone {
+		if e.fast(v.Interface()) {
+			return nil
+		}
+		state, val := optreflect.Get(v)
+		if state != optreflect.StateSet {
+			e.nil()
+			return nil
+		}
+		return e.encode(val)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.bool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.uint(v.Uint())
+	case reflect.Float32:
+		e.float32(float32(v.Float()))
+	case reflect.Float64:
+		e.float64(v.Float())
+	case reflect.String:
+		e.string(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.nil()
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.bytes(v.Bytes())
+			return nil
+		}
+		return e.array(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			for i := range b {
+				b[i] = byte(v.Index(i).Uint())
+			}
+			e.bytes(b)
+			return nil
+		}
+		return e.array(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.nil()
+			return nil
+		}
+		return e.mapping(v)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			e.time(v.Interface().(time.Time))
+			return nil
+		}
+		if optreflect.Opaque(v.Type(), TagName) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		return e.structure(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.nil()
+			return nil
+		}
+		return e.encode(v.Elem())
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+
+	return nil
+}
+
+// fast encodes Vals of the primitive types without going through the
+// reflection based method calls.
+func (e *encoder) fast(x any) bool {
+	switch x := x.(type) {
+	case interface{ Get() (bool, bool) }:
+		fastVal(e, x, e.bool)
+	case interface{ Get() (int, bool) }:
+		fastVal(e, x, func(n int) { e.int(int64(n)) })
+	case interface{ Get() (int8, bool) }:
+		fastVal(e, x, func(n int8) { e.int(int64(n)) })
+	case interface{ Get() (int16, bool) }:
+		fastVal(e, x, func(n int16) { e.int(int64(n)) })
+	case interface{ Get() (int32, bool) }:
+		fastVal(e, x, func(n int32) { e.int(int64(n)) })
+	case interface{ Get() (int64, bool) }:
+		fastVal(e, x, e.int)
+	case interface{ Get() (uint, bool) }:
+		fastVal(e, x, func(n uint) { e.uint(uint64(n)) })
+	case interface{ Get() (uint8, bool) }:
+		fastVal(e, x, func(n uint8) { e.uint(uint64(n)) })
+	case interface{ Get() (uint16, bool) }:
+		fastVal(e, x, func(n uint16) { e.uint(uint64(n)) })
+	case interface{ Get() (uint32, bool) }:
+		fastVal(e, x, func(n uint32) { e.uint(uint64(n)) })
+	case interface{ Get() (uint64, bool) }:
+		fastVal(e, x, e.uint)
+	case interface{ Get() (float32, bool) }:
+		fastVal(e, x, e.float32)
+	case interface{ Get() (float64, bool) }:
+		fastVal(e, x, e.float64)
+	case interface{ Get() (string, bool) }:
+		fastVal(e, x, e.string)
+	case interface{ Get() ([]byte, bool) }:
+		fastVal(e, x, func(b []byte) {
+			if b == nil {
+				e.nil()
+			} else {
+				e.bytes(b)
+			}
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+func fastVal[T any](e *encoder, v interface{ Get() (T, bool) }, write func(T)) {
+	if val, ok := v.Get(); ok {
+		write(val)
+	} else {
+		e.nil()
+	}
+}
+
+func (e *encoder) nil() {
+	e.buf = append(e.buf, formatNil)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf = append(e.buf, formatTrue)
+	} else {
+		e.buf = append(e.buf, formatFalse)
+	}
+}
+
+func (e *encoder) int(n int64) {
+	switch {
+	case n >= 0:
+		e.uint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, formatInt8, byte(n))
+	case n >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, formatInt16), uint16(n))
+	case n >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, formatInt32), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, formatInt64), uint64(n))
+	}
+}
+
+func (e *encoder) uint(n uint64) {
+	switch {
+	case n <= math.MaxInt8:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, formatUint8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, formatUint16), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, formatUint32), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, formatUint64), n)
+	}
+}
+
+func (e *encoder) float32(f float32) {
+	e.buf = binary.BigEndian.AppendUint32(append(e.buf, formatFloat32), math.Float32bits(f))
+}
+
+func (e *encoder) float64(f float64) {
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, formatFloat64), math.Float64bits(f))
+}
+
+func (e *encoder) string(s string) {
+	e.length(len(s), fixStr, 32, formatStr8, formatStr16, formatStr32)
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.length(len(b), 0, 0, formatBin8, formatBin16, formatBin32)
+	e.buf = append(e.buf, b...)
+}
+
+// time writes t as a timestamp in the smallest of its three formats.
+func (e *encoder) time(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec>>34 != 0:
+		e.buf = append(e.buf, formatExt8, 12, extTimestamp)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(nsec))
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(sec))
+	case nsec != 0 || sec>>32 != 0:
+		e.buf = append(e.buf, formatFixExt8, extTimestamp)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, nsec<<34|uint64(sec))
+	default:
+		e.buf = append(e.buf, formatFixExt4, extTimestamp)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(sec))
+	}
+}
+
+// length writes the header for a value of length n. fixed is the format for
+// lengths below fixedMax, when fixedMax is 0 there is no fixed format. f8 is
+// skipped if it is 0.
+func (e *encoder) length(n int, fixed byte, fixedMax int, f8, f16, f32 byte) {
+	switch {
+	case n < fixedMax:
+		e.buf = append(e.buf, fixed|byte(n))
+	case f8 != 0 && n <= math.MaxUint8:
+		e.buf = append(e.buf, f8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, f16), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, f32), uint32(n))
+	}
+}
+
+func (e *encoder) array(v reflect.Value) error {
+	e.length(v.Len(), fixArray, 16, 0, formatArray16, formatArray32)
+	for i := range v.Len() {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) mapping(v reflect.Value) error {
+	e.length(v.Len(), fixMap, 16, 0, formatMap16, formatMap32)
+	iter := v.MapRange()
+	for iter.Next() {
+		if err := e.encode(iter.Key()); err != nil {
+			return err
+		}
+		if err := e.encode(iter.Value()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structure encodes the fields of a struct as a map, leaving out Val fields
+// that are unset.
+func (e *encoder) structure(v reflect.Value) error {
+	fields := optreflect.Fields(v.Type(), TagName)
+	written := fields[:0:0]
+	values := make([]reflect.Value, 0, len(fields))
+	for _, f := range fields {
+		field, ok := f.Value(v)
+		if !ok || optreflect.KindOf(f.Type) != optreflect.KindNone &&
+			optreflect.StateOf(field) == optreflect.StateUnset {
+			continue
+		}
+		written = append(written, f)
+		values = append(values, field)
+	}
+
+	e.length(len(written), fixMap, 16, 0, formatMap16, formatMap32)
+	for i, f := range written {
+		e.string(f.Name)
+		if err := e.encode(values[i]); err != nil {
+			return fmt.Errorf("%w (field %s)", err, f.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Package msgpack encodes and decodes structs of omit, null and omitnull
+// values and Go primitives as MessagePack using only the standard library.
+//
+// The states of the Val types are mapped as follows:
+//
+//	State | Struct field     | Anywhere else
+//	------|------------------|--------------
+//	unset | key is omitted   | nil
+//	null  | nil              | nil
+//	set   | the value        | the value
+//
+// When decoding, a missing key leaves a field untouched (so it stays unset in
+// a fresh struct) and nil makes a null or omitnull value null. Decoding nil
+// into an omit.Val is an error, the same as omit.Val.UnmarshalJSON.
+//
+// Struct keys come from the `msgpack` struct tag and fall back to the Go field
+// name. Fields tagged "-" are skipped.
+//
+// Integers are written in the smallest format that holds them, floats keep
+// their Go size, []byte and [N]byte use the bin format, slices and arrays the
+// array format and maps and structs the map format. Nil pointers, slices and
+// maps are encoded as nil. time.Time uses the timestamp extension type (-1)
+// and is decoded in UTC, the other extension types are not supported. Structs
+// without any exported fields cannot be encoded or decoded.
+//
+// Arrays and maps may be nested at most MaxDepth levels deep when decoding.
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TagName is the struct tag that map keys are read from.
+const TagName = "msgpack"
+
+// MaxDepth is the maximum nesting depth of the values that Unmarshal accepts.
+const MaxDepth = 1000
+
+var (
+	// ErrSyntax is returned when the data is not valid MessagePack.
+	ErrSyntax = errors.New("msgpack: malformed data")
+	// ErrUnsupportedType is returned when a Go type cannot be encoded or
+	// decoded, or the data contains an extension type.
+	ErrUnsupportedType = errors.New("msgpack: unsupported type")
+	// ErrTypeMismatch is returned when a MessagePack value cannot be decoded
+	// into the Go value it was destined for.
+	ErrTypeMismatch = errors.New("msgpack: type mismatch")
+	// ErrMaxDepth is returned when the data is nested deeper than MaxDepth.
+	ErrMaxDepth = errors.New("msgpack: exceeded max depth")
+)
+
+// extTimestamp is the extension type of timestamps.
+const extTimestamp byte = 0xff
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// Marshal encodes v as MessagePack.
+func Marshal(v any) ([]byte, error) {
+	e := &encoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes the single MessagePack value in data into the value
+// pointed to by v.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: must decode into a non-nil pointer, got %T", ErrUnsupportedType, v)
+	}
+
+	d := &decoder{data: data}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.off != len(d.data) {
+		return fmt.Errorf("%w: trailing data after value", ErrSyntax)
+	}
+	return nil
+}
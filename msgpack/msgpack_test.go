@@ -0,0 +1,501 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type testStruct struct {
+	Omit     omit.Val[int]         `msgpack:"o"`
+	Null     null.Val[string]      `msgpack:"n"`
+	OmitNull omitnull.Val[float64] `msgpack:"on"`
+	Plain    uint8                 `msgpack:"p"`
+	Ptr      *int                  `msgpack:"ptr"`
+	List     []omitnull.Val[int]   `msgpack:"list"`
+	Ignored  int                   `msgpack:"-"`
+}
+
+type myInt int
+
+func TestMarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"nil", nil, "c0"},
+		{"true", true, "c3"},
+		{"false", false, "c2"},
+		{"fixint", 127, "7f"},
+		{"uint8", 128, "cc80"},
+		{"uint16", 1000, "cd03e8"},
+		{"uint32", 1000000, "ce000f4240"},
+		{"uint64", uint64(math.MaxUint64), "cfffffffffffffffff"},
+		{"negfix", -32, "e0"},
+		{"int8", -33, "d0df"},
+		{"int16", -1000, "d1fc18"},
+		{"int32", -100000, "d2fffe7960"},
+		{"int64", int64(math.MinInt64), "d38000000000000000"},
+		{"float32", float32(1.5), "ca3fc00000"},
+		{"float64", 1.5, "cb3ff8000000000000"},
+		{"fixstr", "abc", "a3616263"},
+		{"str8", strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{"bin", []byte{1, 2}, "c4020102"},
+		{"byte array", [2]byte{1, 2}, "c4020102"},
+		{"nil bytes", []byte(nil), "c0"},
+		{"array", []int{1, -1}, "9201ff"},
+		{"map", map[string]int{"a": 1}, "81a16101"},
+		{"nil ptr", (*int)(nil), "c0"},
+		{"unset omit", omit.Val[int]{}, "c0"},
+		{"set omit", omit.From(1), "01"},
+		{"null", null.Val[string]{}, "c0"},
+		{"set null", null.From("a"), "a161"},
+		{"null omitnull", omitnull.FromPtr[int](nil), "c0"},
+		{"set omitnull bytes", omitnull.From([]byte{}), "c400"},
+		{"non primitive val", omit.From(myInt(-1)), "ff"},
+		{"struct", testStruct{Null: null.From(""), Plain: 1}, "84a16ea0a17001a3707472c0a46c697374c0"},
+		{"struct set", testStruct{
+			Omit:     omit.From(1),
+			OmitNull: omitnull.FromPtr[float64](nil),
+			List:     []omitnull.Val[int]{omitnull.From(2)},
+		}, "86a16f01a16ec0a26f6ec0a17000a3707472c0a46c6973749102"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := Marshal(test.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := mustHex(t, test.want); !bytes.Equal(b, want) {
+				t.Errorf("want: %x got: %x", want, b)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	one := 1
+	in := testStruct{
+		Omit:     omit.From(-500),
+		Null:     null.From(""),
+		OmitNull: omitnull.From(1.5),
+		Plain:    255,
+		Ptr:      &one,
+		List:     []omitnull.Val[int]{omitnull.FromPtr[int](nil), omitnull.From(0)},
+		Ignored:  5,
+	}
+
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out testStruct
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := in
+	want.Ignored = 0
+	if !reflect.DeepEqual(want, out) {
+		t.Errorf("want: %#v\ngot:  %#v", want, out)
+	}
+}
+
+func TestPrimitives(t *testing.T) {
+	t.Parallel()
+
+	t.Run("int8", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[int8]
+			Null     null.Val[int8]
+			OmitNull omitnull.Val[int8]
+		}
+
+		for _, v := range []int8{math.MinInt8, -1, 0, math.MaxInt8} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[int8](nil)})
+	})
+	t.Run("int16", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[int16]
+			Null     null.Val[int16]
+			OmitNull omitnull.Val[int16]
+		}
+
+		for _, v := range []int16{math.MinInt16, -1, 0, math.MaxInt16} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[int16](nil)})
+	})
+	t.Run("int32", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[int32]
+			Null     null.Val[int32]
+			OmitNull omitnull.Val[int32]
+		}
+
+		for _, v := range []int32{math.MinInt32, -1, 0, math.MaxInt32} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[int32](nil)})
+	})
+	t.Run("int64", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[int64]
+			Null     null.Val[int64]
+			OmitNull omitnull.Val[int64]
+		}
+
+		for _, v := range []int64{math.MinInt64, -1, 0, math.MaxInt64} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[int64](nil)})
+	})
+	t.Run("int", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[int]
+			Null     null.Val[int]
+			OmitNull omitnull.Val[int]
+		}
+
+		for _, v := range []int{math.MinInt, -1, 0, math.MaxInt} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[int](nil)})
+	})
+	t.Run("uint8", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[uint8]
+			Null     null.Val[uint8]
+			OmitNull omitnull.Val[uint8]
+		}
+
+		for _, v := range []uint8{0, math.MaxUint8} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[uint8](nil)})
+	})
+	t.Run("uint16", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[uint16]
+			Null     null.Val[uint16]
+			OmitNull omitnull.Val[uint16]
+		}
+
+		for _, v := range []uint16{0, math.MaxUint16} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[uint16](nil)})
+	})
+	t.Run("uint32", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[uint32]
+			Null     null.Val[uint32]
+			OmitNull omitnull.Val[uint32]
+		}
+
+		for _, v := range []uint32{0, math.MaxUint32} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[uint32](nil)})
+	})
+	t.Run("uint64", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[uint64]
+			Null     null.Val[uint64]
+			OmitNull omitnull.Val[uint64]
+		}
+
+		for _, v := range []uint64{0, math.MaxUint64} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[uint64](nil)})
+	})
+	t.Run("uint", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[uint]
+			Null     null.Val[uint]
+			OmitNull omitnull.Val[uint]
+		}
+
+		for _, v := range []uint{0, math.MaxUint} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[uint](nil)})
+	})
+	t.Run("float32", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[float32]
+			Null     null.Val[float32]
+			OmitNull omitnull.Val[float32]
+		}
+
+		for _, v := range []float32{0, -1.5, math.MaxFloat32} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[float32](nil)})
+	})
+	t.Run("float64", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[float64]
+			Null     null.Val[float64]
+			OmitNull omitnull.Val[float64]
+		}
+
+		for _, v := range []float64{0, -1.5, math.MaxFloat64} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[float64](nil)})
+	})
+	t.Run("string", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[string]
+			Null     null.Val[string]
+			OmitNull omitnull.Val[string]
+		}
+
+		for _, v := range []string{"", "hello", strings.Repeat("a", 300)} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[string](nil)})
+	})
+	t.Run("[]byte", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[[]byte]
+			Null     null.Val[[]byte]
+			OmitNull omitnull.Val[[]byte]
+		}
+
+		for _, v := range [][]byte{{}, []byte("hello")} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[[]byte](nil)})
+	})
+	t.Run("bool", func(t *testing.T) {
+		type vals struct {
+			Omit     omit.Val[bool]
+			Null     null.Val[bool]
+			OmitNull omitnull.Val[bool]
+		}
+
+		for _, v := range []bool{false, true} {
+			roundTrip(t, vals{Omit: omit.From(v), Null: null.From(v), OmitNull: omitnull.From(v)})
+		}
+		roundTrip(t, vals{})
+		roundTrip(t, vals{OmitNull: omitnull.FromPtr[bool](nil)})
+	})
+}
+
+func roundTrip[T any](t *testing.T, in T) {
+	t.Helper()
+
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out T
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("want: %#v got: %#v", in, out)
+	}
+}
+
+func TestUnmarshalStates(t *testing.T) {
+	t.Parallel()
+
+	out := testStruct{
+		Omit:     omit.From(1),
+		Null:     null.From("stale"),
+		OmitNull: omitnull.From(1.0),
+	}
+	// {"n": nil, "on": nil, "x": [1, {"a": bin(0)}]}
+	if err := Unmarshal(mustHex(t, "83a16ec0a26f6ec0a1789201 81a161c400"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Omit.MustGet() != 1 {
+		t.Error("missing key should leave the field alone")
+	}
+	if !out.Null.IsNull() {
+		t.Error("nil should make null.Val null")
+	}
+	if !out.OmitNull.IsNull() {
+		t.Error("nil should make omitnull.Val null")
+	}
+
+	err := Unmarshal(mustHex(t, "81a16fc0"), &out)
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Error("expected a type mismatch for nil into omit, got:", err)
+	}
+
+	var o omit.Val[myInt]
+	if err := Unmarshal(mustHex(t, "c0"), &o); !errors.Is(err, ErrTypeMismatch) {
+		t.Error("expected a type mismatch for nil into omit, got:", err)
+	}
+	if err := Unmarshal(mustHex(t, "d0df"), &o); err != nil {
+		t.Fatal(err)
+	} else if o.MustGet() != -33 {
+		t.Error("value wrong:", o.MustGet())
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		out  any
+		want any
+	}{
+		{"signed into uint", "d001", new(uint), uint(1)},
+		{"unsigned into int", "cc80", new(int16), int16(128)},
+		{"int into float", "ff", new(float64), -1.0},
+		{"float32 into float64", "ca3fc00000", new(float64), 1.5},
+		{"str into bytes", "a3616263", new([]byte), []byte("abc")},
+		{"array", "93010203", new([2]int), [2]int{1, 2}},
+		{"ptr", "01", new(*int), func() *int { i := 1; return &i }()},
+		{"nil ptr", "c0", new(*int), (*int)(nil)},
+		{"nil into int", "c0", new(int), 0},
+		{"any", "82a161ffa1629202cb3ff8000000000000", new(any), map[string]any{"a": int64(-1), "b": []any{int64(2), 1.5}}},
+		{"any uint64", "cfffffffffffffffff", new(any), uint64(math.MaxUint64)},
+		{"skip unknown", "82a178d7010000000000000000 a16ec0", new(testStruct), testStruct{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Unmarshal(mustHex(t, test.in), test.out); err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(test.out).Elem().Interface(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("want: %#v got: %#v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		out  any
+		err  error
+	}{
+		{"empty", "", new(int), ErrSyntax},
+		{"truncated", "cd03", new(int), ErrSyntax},
+		{"trailing", "0101", new(int), ErrSyntax},
+		{"long array", "ddffffffff", new([]int), ErrSyntax},
+		{"long string", "dbffffffff", new(string), ErrSyntax},
+		{"overflow", "cd0100", new(uint8), ErrTypeMismatch},
+		{"overflow val", "cd0100", new(omit.Val[uint8]), ErrTypeMismatch},
+		{"negative uint", "ff", new(uint), ErrTypeMismatch},
+		{"negative uint val", "ff", new(null.Val[uint]), ErrTypeMismatch},
+		{"mismatch", "a161", new(int), ErrTypeMismatch},
+		{"mismatch val", "a161", new(omitnull.Val[int]), ErrTypeMismatch},
+		{"ext", "d40100", new(any), ErrUnsupportedType},
+		{"not a pointer", "01", 0, ErrUnsupportedType},
+		{"opaque struct", "80", new(struct{ x int }), ErrUnsupportedType},
+		{"time ext type", "d60100000001", new(time.Time), ErrTypeMismatch},
+		{"time nanos", "d7ffffffffff00000000", new(time.Time), ErrSyntax},
+		{"deep array", strings.Repeat("91", MaxDepth+1) + "01", new(any), ErrMaxDepth},
+		{"deep skip", "81a178" + strings.Repeat("91", MaxDepth+1) + "01", new(testStruct), ErrMaxDepth},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Unmarshal(mustHex(t, test.in), test.out)
+			if !errors.Is(err, test.err) {
+				t.Errorf("want: %v got: %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   time.Time
+		want string
+	}{
+		{"32 bit", time.Unix(1, 0), "d6ff00000001"},
+		{"64 bit", time.Unix(1, 5), "d7ff0000001400000001"},
+		{"96 bit", time.Unix(-1, 0), "c70cff00000000ffffffffffffffff"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := Marshal(omitnull.From(test.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(b); got != test.want {
+				t.Errorf("want: %s got: %s", test.want, got)
+			}
+
+			var got omitnull.Val[time.Time]
+			if err := Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.MustGet().Equal(test.in) {
+				t.Errorf("want: %v got: %v", test.in, got.MustGet())
+			}
+
+			var x any
+			if err := Unmarshal(b, &x); err != nil {
+				t.Fatal(err)
+			}
+			if tm, ok := x.(time.Time); !ok || !tm.Equal(test.in) {
+				t.Errorf("want: %v got: %#v", test.in, x)
+			}
+		})
+	}
+
+	if _, err := Marshal(struct{ x int }{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("opaque struct should not encode: %v", err)
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
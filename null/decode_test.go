@@ -0,0 +1,86 @@
+package null
+
+import (
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aarondl/opt"
+)
+
+func TestDecodeErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		decode func(*Val[int]) error
+		source any
+		state  string
+	}{
+		{"Scan", func(v *Val[int]) error { return v.Scan("x") }, "", "set"},
+		{"UnmarshalJSON", func(v *Val[int]) error { return v.UnmarshalJSON([]byte(`"x"`)) }, []byte{}, "set"},
+		{"UnmarshalJSON empty", func(v *Val[int]) error { return v.UnmarshalJSON([]byte{}) }, []byte{}, "unset"},
+		{"UnmarshalText", func(v *Val[int]) error { return v.UnmarshalText([]byte("x")) }, []byte{}, "set"},
+		{"UnmarshalBinary", func(v *Val[int]) error { return v.UnmarshalBinary([]byte{0xff, 'o', 'p', 1, 9}) }, []byte{}, ""},
+		{"UnmarshalBinary legacy", func(v *Val[int]) error { return v.UnmarshalBinary([]byte("x")) }, []byte{}, "set"},
+		{"GobDecode", func(v *Val[int]) error { return v.GobDecode([]byte{0xff, 'o', 'p', 1}) }, []byte{}, ""},
+		{"GobDecode state", func(v *Val[int]) error { return v.GobDecode([]byte{0xff, 'o', 'p', 1, 9}) }, []byte{}, ""},
+		{"UnmarshalTextWith", func(v *Val[int]) error { return v.UnmarshalTextWith([]byte("x"), opt.TextNull) }, []byte{}, "set"},
+		{"UnmarshalXML", func(v *Val[int]) error { return xml.Unmarshal([]byte("<v>x</v>"), v) }, xml.StartElement{}, "set"},
+		{"UnmarshalXMLAttr", func(v *Val[int]) error { return v.UnmarshalXMLAttr(xml.Attr{Value: "x"}) }, xml.Attr{}, "set"},
+		{"UnmarshalYAML", func(v *Val[int]) error {
+			return v.UnmarshalYAML(func(any) error { return errors.New("bad yaml") })
+		}, nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, val := range []Val[int]{From(5), {}} {
+				before := val
+				err := test.decode(&val)
+
+				var decodeErr *opt.DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatal("expected a DecodeError, got:", err)
+				}
+				if decodeErr.Source != reflect.TypeOf(test.source) {
+					t.Error("source wrong:", decodeErr.Source)
+				}
+				if decodeErr.Target != reflect.TypeFor[int]() {
+					t.Error("target wrong:", decodeErr.Target)
+				}
+				if decodeErr.State != test.state {
+					t.Errorf("state wrong: %q", decodeErr.State)
+				}
+				if val != before {
+					t.Errorf("value changed from %#v to %#v", before, val)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeErrorPartial(t *testing.T) {
+	t.Parallel()
+
+	type pair struct {
+		A, B int
+	}
+
+	val := From(pair{A: 1, B: 2})
+	if err := val.UnmarshalJSON([]byte(`{"A":5,"B":"x"}`)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := val.MustGet(); got != (pair{A: 1, B: 2}) {
+		t.Error("partially decoded value leaked:", got)
+	}
+
+	nums := From([]int{1})
+	if err := nums.Scan("{2,x}"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := nums.MustGet(); len(got) != 1 || got[0] != 1 {
+		t.Error("partially scanned value leaked:", got)
+	}
+}
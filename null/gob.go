@@ -0,0 +1,30 @@
+package null
+
+import (
+	"github.com/aarondl/opt/internal/binfmt"
+	"github.com/aarondl/opt/internal/decode"
+)
+
+// GobEncode implements gob.GobEncoder. It uses the same encoding as
+// MarshalBinary which keeps the state, so every state and value survives a
+// round trip.
+func (v Val[T]) GobEncode() ([]byte, error) {
+	return v.AppendBinary(nil)
+}
+
+// GobDecode implements gob.GobDecoder, reversing GobEncode. An unset value (as
+// encoded by the omit and omitnull packages) is decoded as null.
+func (v *Val[T]) GobDecode(b []byte) error {
+	state, value, err := binfmt.Decode[T](b)
+	if err != nil {
+		return decode.Error[T](b, decode.StateUnknown, err)
+	}
+
+	if state == binfmt.StateSet {
+		v.state = StateSet
+	} else {
+		v.state = StateNull
+	}
+	v.value = value
+	return nil
+}
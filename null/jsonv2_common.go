@@ -0,0 +1,37 @@
+package null
+
+import "github.com/aarondl/opt/internal/decode"
+
+// marshalJSONTo holds the logic of MarshalJSONTo, which is declared in build
+// tagged files that only make the encoding/json/v2 calls. writeNull writes a
+// null to the encoder and encode encodes a value to it.
+func (v Val[T]) marshalJSONTo(writeNull func() error, encode func(any) error) error {
+	if v.state != StateSet {
+		return writeNull()
+	}
+	return encode(v.value)
+}
+
+// unmarshalJSONFrom holds the logic of UnmarshalJSONFrom, see marshalJSONTo.
+// src is the decoder, isNull reports whether its next value is null,
+// readNull consumes that null and decodeValue decodes the next value into
+// the pointer it is given.
+func (v *Val[T]) unmarshalJSONFrom(src any, isNull bool, readNull func() error, decodeValue func(any) error) error {
+	if isNull {
+		if err := readNull(); err != nil {
+			return decode.Error[T](src, decode.StateNull, err)
+		}
+		var zero T
+		v.value = zero
+		v.state = StateNull
+		return nil
+	}
+
+	var value T
+	if err := decodeValue(&value); err != nil {
+		return decode.Error[T](src, decode.StateSet, err)
+	}
+	v.value = value
+	v.state = StateSet
+	return nil
+}
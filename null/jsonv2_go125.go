@@ -0,0 +1,39 @@
+//go:build go1.25 && !go1.27 && goexperiment.jsonv2
+
+// This declares the same methods as jsonv2.go for Go 1.25 and 1.26, where
+// encoding/json/v2 is only available with GOEXPERIMENT=jsonv2. The two cannot
+// share a file because the package is part of the go1.27 API and this module
+// targets an older Go version, so its files need a go1.27 build constraint to
+// use it there. Both only wrap the encoding/json/v2 calls, the logic lives in
+// jsonv2_common.go.
+
+package null
+
+import (
+	"encoding/json/jsontext"
+	json "encoding/json/v2"
+)
+
+// MarshalJSONTo implements json.MarshalerTo from encoding/json/v2. The value is
+// written directly to the encoder using the encoder's options, so options
+// like json.StringifyNumbers apply to T as well.
+//
+// Values that are not set are written as null, use the omitzero tag option
+// to leave them out of objects instead.
+func (v Val[T]) MarshalJSONTo(enc *jsontext.Encoder) error {
+	return v.marshalJSONTo(
+		func() error { return enc.WriteToken(jsontext.Null) },
+		func(value any) error { return json.MarshalEncode(enc, value) },
+	)
+}
+
+// UnmarshalJSONFrom implements json.UnmarshalerFrom from encoding/json/v2.
+func (v *Val[T]) UnmarshalJSONFrom(dec *jsontext.Decoder) error {
+	return v.unmarshalJSONFrom(dec, dec.PeekKind() == 'n',
+		func() error {
+			_, err := dec.ReadToken()
+			return err
+		},
+		func(value any) error { return json.UnmarshalDecode(dec, value) },
+	)
+}
@@ -0,0 +1,44 @@
+//go:build go1.27 && goexperiment.jsonv2
+
+package null
+
+import (
+	json "encoding/json/v2"
+	"testing"
+)
+
+func TestJSONv2(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		A Val[int]    `json:"a"`
+		B Val[string] `json:"b"`
+	}
+
+	in := testStruct{A: From(5)}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":5,"b":null}` {
+		t.Error("json wrong:", string(b))
+	}
+
+	b, err = json.Marshal(in, json.StringifyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":"5","b":null}` {
+		t.Error("json wrong:", string(b))
+	}
+
+	out := testStruct{B: From("hi")}
+	if err := json.Unmarshal([]byte(`{"a":"6","b":null}`), &out, json.StringifyNumbers(true)); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out.A, StateSet)
+	checkState(t, out.B, StateNull)
+	if out.A.MustGet() != 6 {
+		t.Errorf("values wrong: %#v", out)
+	}
+}
@@ -4,12 +4,14 @@ package null
 
 import (
 	"bytes"
+	"database/sql"
 	"database/sql/driver"
-	"encoding"
 	"errors"
-	"reflect"
 
 	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/appender"
+	"github.com/aarondl/opt/internal/binfmt"
+	"github.com/aarondl/opt/internal/decode"
 	"github.com/aarondl/opt/internal/globaldata"
 )
 
@@ -72,6 +74,12 @@ func FromCond[T any](val T, ok bool) Val[T] {
 	}
 }
 
+// FromSQL creates a value from a database/sql.Null, it is null if the
+// sql.Null is not valid.
+func FromSQL[T any](val sql.Null[T]) Val[T] {
+	return FromCond(val.V, val.Valid)
+}
+
 // Get the underlying value, if one exists.
 func (v Val[T]) Get() (T, bool) {
 	if v.state == StateSet {
@@ -127,6 +135,17 @@ func (v Val[T]) Or(other Val[T]) Val[T] {
 	}
 }
 
+// Overlay layers a later value on top of v. Since a nullable value has no
+// unset state for v to show through, the result is always other. It exists so
+// all three Val types can be combined the same way.
+//
+//	v     | other | result
+//	------------- | -------
+//	_     | _     | other
+func (v Val[T]) Overlay(other Val[T]) Val[T] {
+	return other
+}
+
 // Map transforms the value inside if it is set, else it returns a value of the
 // same state.
 //
@@ -191,6 +210,11 @@ func (v Val[T]) Ptr() *T {
 	return nil
 }
 
+// SQL converts the value to a database/sql.Null which is valid when v is set.
+func (v Val[T]) SQL() sql.Null[T] {
+	return sql.Null[T]{V: v.value, Valid: v.state == StateSet}
+}
+
 // State retrieves the internal state, mostly useful for testing.
 func (v Val[T]) State() state {
 	return v.state
@@ -200,17 +224,18 @@ func (v Val[T]) State() state {
 func (v *Val[T]) UnmarshalJSON(data []byte) error {
 	switch {
 	case len(data) == 0:
-		return errors.New("cannot unmarshal empty bytes into null value")
+		return decode.Error[T](data, decode.StateUnset, errors.New("cannot unmarshal empty bytes into null value"))
 	case bytes.Equal(data, globaldata.JSONNull):
 		var zero T
 		v.value = zero
 		v.state = StateNull
 		return nil
 	default:
-		err := opt.JSONUnmarshal(data, &v.value)
-		if err != nil {
-			return err
+		var value T
+		if err := opt.JSONUnmarshal(data, &value); err != nil {
+			return decode.Error[T](data, decode.StateSet, err)
 		}
+		v.value = value
 		v.state = StateSet
 		return nil
 	}
@@ -226,6 +251,15 @@ func (v Val[T]) MarshalJSON() ([]byte, error) {
 	}
 }
 
+// AppendText implements encoding.TextAppender. Nothing is appended for null
+// values, see MarshalText.
+func (v Val[T]) AppendText(b []byte) ([]byte, error) {
+	if v.state != StateSet {
+		return b, nil
+	}
+	return appender.Text(b, v.value)
+}
+
 // MarshalText implements encoding.TextMarshaler.
 //
 // This package emits an empty string for null values
@@ -241,23 +275,13 @@ func (v Val[T]) MarshalJSON() ([]byte, error) {
 // as a sentinel value, but for now this package will assume that
 // any value being consumed by null.UnmarshalText() has been
 // created with null.MarshalText() and it therefore strives to make
-// no gesture of compatibility with non-null.Val serialized types.
+// no gesture of compatibility with non-null.Val serialized types. Use
+// MarshalTextWith for text that is shared with other systems.
 func (v Val[T]) MarshalText() ([]byte, error) {
 	if v.state != StateSet {
 		return nil, nil
 	}
-
-	refVal := reflect.ValueOf(v.value)
-	if refVal.Type().Implements(globaldata.EncodingTextMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextMarshaler)
-		return valuer.MarshalText()
-	}
-
-	var text string
-	if err := opt.ConvertAssign(&text, v.value); err != nil {
-		return nil, err
-	}
-	return []byte(text), nil
+	return v.AppendText([]byte{})
 }
 
 // UnmarshalText implements encoding.TextUnmarshaler.
@@ -273,56 +297,67 @@ func (v *Val[T]) UnmarshalText(text []byte) error {
 		return nil
 	}
 
-	refVal := reflect.ValueOf(&v.value)
-	if refVal.Type().Implements(globaldata.EncodingTextUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextUnmarshaler)
-		if err := valuer.UnmarshalText(text); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
-	}
-
-	if err := opt.ConvertAssign(&v.value, string(text)); err != nil {
-		return err
+	var value T
+	if err := decode.Text(&value, text); err != nil {
+		return decode.Error[T](text, decode.StateSet, err)
 	}
-
+	v.value = value
 	v.state = StateSet
 	return nil
 }
 
-// MarshalBinary tries to encode the value in binary. If it finds
-// type that implements encoding.BinaryMarshaler it will use that,
-// it will fallback to encoding.TextMarshaler if that is implemented,
-// and failing that it will attempt to do some reflect to convert between
-// the types to hit common cases like Go primitives.
-func (v Val[T]) MarshalBinary() ([]byte, error) {
+// AppendBinary implements encoding.BinaryAppender. See MarshalBinary for
+// details about the encoding.
+func (v Val[T]) AppendBinary(b []byte) ([]byte, error) {
 	if v.state != StateSet {
-		return nil, nil
+		return binfmt.Append(b, binfmt.StateNull, v.value)
 	}
+	return binfmt.Append(b, binfmt.StateSet, v.value)
+}
 
-	refVal := reflect.ValueOf(v.value)
-	if refVal.Type().Implements(globaldata.EncodingBinaryMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.BinaryMarshaler)
-		return valuer.MarshalBinary()
-	}
+// MarshalBinary encodes the state and value in a compact binary format.
+// Integers are written as varints, floats as IEEE-754, strings and bytes are
+// length prefixed and time.Time has a fixed width. Other types use
+// encoding.BinaryMarshaler if they implement it and their text representation
+// (see MarshalText) if not.
+//
+// The encoding starts with a header and version followed by the state, so a set
+// empty value is always distinct from null.
+func (v Val[T]) MarshalBinary() ([]byte, error) {
+	return v.AppendBinary(nil)
+}
 
-	if refVal.Type().Implements(globaldata.EncodingTextMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextMarshaler)
-		return valuer.MarshalText()
+// UnmarshalBinary reverses MarshalBinary. An unset value (as encoded by the
+// omit and omitnull packages) is decoded as null.
+//
+// Data that does not start with the header is decoded in the legacy
+// format written by previous versions, where a nil slice was null and
+// anything else was the binary or text encoding of the value. A legacy
+// encoding of a []byte or encoding.BinaryMarshaler value that happens to
+// start with the header ("\xffop") cannot be told apart from the current
+// format.
+func (v *Val[T]) UnmarshalBinary(b []byte) error {
+	if !binfmt.IsVersioned(b) {
+		return v.unmarshalBinaryLegacy(b)
 	}
 
-	var buf []byte
-	if err := opt.ConvertAssign(&buf, v.value); err != nil {
-		return nil, err
+	state, value, err := binfmt.Decode[T](b)
+	if err != nil {
+		return decode.Error[T](b, decode.StateUnknown, err)
 	}
 
-	return buf, nil
+	if state == binfmt.StateSet {
+		v.state = StateSet
+	} else {
+		v.state = StateNull
+	}
+	v.value = value
+	return nil
 }
 
-// UnmarshalBinary tries to reverse the value MarshalBinary operation.
-// See documentation there for details about supported types.
-func (v *Val[T]) UnmarshalBinary(b []byte) error {
+// unmarshalBinaryLegacy decodes the format MarshalBinary used before the
+// header was introduced.
+func (v *Val[T]) unmarshalBinaryLegacy(b []byte) error {
 	if b == nil {
 		var zero T
 		v.value = zero
@@ -330,35 +365,18 @@ func (v *Val[T]) UnmarshalBinary(b []byte) error {
 		return nil
 	}
 
-	refVal := reflect.ValueOf(&v.value)
-	if refVal.Type().Implements(globaldata.EncodingBinaryUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.BinaryUnmarshaler)
-		if err := valuer.UnmarshalBinary(b); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
-	}
-
-	if refVal.Type().Implements(globaldata.EncodingTextUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextUnmarshaler)
-		if err := valuer.UnmarshalText(b); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
-	}
-
-	if err := opt.ConvertAssign(&v.value, b); err != nil {
-		return err
+	var value T
+	if err := decode.Binary(&value, b); err != nil {
+		return decode.Error[T](b, decode.StateSet, err)
 	}
-
+	v.value = value
 	v.state = StateSet
 	return nil
 }
 
 // Scan implements the sql.Scanner interface. If the wrapped type implements
-// sql.Scanner then it will call that.
+// sql.Scanner then it will call that. Slices other than []byte are parsed
+// from Postgres arrays, see opt.ParsePGArray.
 func (v *Val[T]) Scan(value any) error {
 	if value == nil {
 		var zero T
@@ -366,8 +384,14 @@ func (v *Val[T]) Scan(value any) error {
 		v.state = StateNull
 		return nil
 	}
+
+	var val T
+	if err := opt.ConvertAssign(&val, value); err != nil {
+		return decode.Error[T](value, decode.StateSet, err)
+	}
+	v.value = val
 	v.state = StateSet
-	return opt.ConvertAssign(&v.value, value)
+	return nil
 }
 
 // Value implements the driver.Valuer interface. If the underlying type
@@ -380,12 +404,15 @@ func (v *Val[T]) Scan(value any) error {
 //	[]byte
 //	string
 //	time.Time
+//
+// Slices other than []byte are written as Postgres arrays, see
+// opt.AppendPGArray.
 func (v Val[T]) Value() (driver.Value, error) {
 	if v.state != StateSet {
 		return nil, nil
 	}
 
-	return opt.ToDriverValue(v.value)
+	return opt.ToDriverValueArray(v.value)
 }
 
 // Equal compares two nullable values and returns true if they are equal.
@@ -401,3 +428,18 @@ func Equal[T comparable](a, b Val[T]) bool {
 
 	return a.value == b.value
 }
+
+// EqualFunc compares two nullable values and returns true if they are equal.
+// It is like Equal but uses eq to compare the values when both are set
+// which allows it to work with types that are not comparable.
+func EqualFunc[T any](a, b Val[T], eq func(T, T) bool) bool {
+	if a.state != b.state {
+		return false
+	}
+
+	if a.state != StateSet {
+		return true
+	}
+
+	return eq(a.value, b.value)
+}
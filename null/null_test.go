@@ -2,8 +2,11 @@ package null
 
 import (
 	"bytes"
+	"database/sql"
 	"database/sql/driver"
 	"net"
+	"reflect"
+	"slices"
 	"testing"
 	"time"
 
@@ -106,6 +109,24 @@ func TestOr(t *testing.T) {
 	}
 }
 
+func TestOverlay(t *testing.T) {
+	t.Parallel()
+
+	var set, null Val[int]
+	set.Set(5)
+	null.Null()
+
+	if set.Overlay(From(6)).MustGet() != 6 {
+		t.Error("it should have returned 6")
+	}
+	if !set.Overlay(null).IsNull() {
+		t.Error("it should have returned null")
+	}
+	if null.Overlay(set).MustGet() != 5 {
+		t.Error("it should have returned 5")
+	}
+}
+
 func TestMap(t *testing.T) {
 	t.Parallel()
 
@@ -251,34 +272,79 @@ func TestMarshalBinary(t *testing.T) {
 	t.Parallel()
 
 	hello := From("hello")
-	b, err := hello.MarshalText()
+	b, err := hello.MarshalBinary()
 	if err != nil {
 		t.Error(err)
 	}
-	if string(b) != "hello" {
-		t.Error("expected hello")
+	if string(b) != "\xffop\x01\x02\x06\x05hello" {
+		t.Error("wrong value:", b)
 	}
 
-	hello.Null()
-	b, err = hello.MarshalText()
+	b, err = From(int64(-300)).MarshalBinary()
 	if err != nil {
 		t.Error(err)
 	}
-	if len(b) != 0 {
-		t.Error("expected empty byte slice")
+	if !bytes.Equal(b, []byte{0xff, 'o', 'p', 1, 2, 1, 0xd7, 0x04}) {
+		t.Error("wrong value:", b)
 	}
 
-	marshaller := From(net.IPv4(1, 1, 1, 1))
-	if b, err := marshaller.MarshalText(); err != nil {
+	null := Val[string]{}
+	b, err = null.MarshalBinary()
+	if err != nil {
 		t.Error(err)
-	} else if !bytes.Equal(b, []byte("1.1.1.1")) {
-		t.Error("wrong value")
+	}
+	if !bytes.Equal(b, []byte{0xff, 'o', 'p', 1, 1}) {
+		t.Error("wrong value:", b)
 	}
 }
 
 func TestUnmarshalBinary(t *testing.T) {
 	t.Parallel()
 
+	date := time.Date(2000, 1, 1, 2, 30, 0, 5, time.FixedZone("", -7*3600))
+	roundTripBinary(t, From(""))
+	roundTripBinary(t, From([]byte{}))
+	roundTripBinary(t, From(int8(-128)))
+	roundTripBinary(t, From(uint64(1<<64-1)))
+	roundTripBinary(t, From(float32(1.5)))
+	roundTripBinary(t, From(true))
+	roundTripBinary(t, From(net.IPv4(1, 1, 1, 1)))
+	roundTripBinary(t, From(date.UTC()))
+	roundTripBinary(t, From(date))
+
+	val := From("stale")
+	if err := val.UnmarshalBinary([]byte{0xff, 'o', 'p', 1, 0}); err != nil {
+		t.Error(err)
+	}
+	checkState(t, val, StateNull)
+
+	var small Val[int8]
+	big, _ := From(1000).MarshalBinary()
+	if err := small.UnmarshalBinary(big); err == nil {
+		t.Error("expected an overflow error")
+	}
+}
+
+func roundTripBinary[T any](t *testing.T, in Val[T]) {
+	t.Helper()
+
+	b, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Val[T]
+	if err := out.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out, in.State())
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("want: %#v got: %#v", in, out)
+	}
+}
+
+func TestUnmarshalBinaryLegacy(t *testing.T) {
+	t.Parallel()
+
 	var val Val[string]
 	checkState(t, val, StateNull)
 	if err := val.UnmarshalBinary([]byte("hello")); err != nil {
@@ -309,6 +375,122 @@ func TestUnmarshalBinary(t *testing.T) {
 	if !unmarshaller.MustGet().Equal(net.IPv4(1, 1, 1, 1)) {
 		t.Error("wrong value")
 	}
+
+	var raw Val[[]byte]
+	if err := raw.UnmarshalBinary([]byte{0xff, 2}); err != nil {
+		t.Error(err)
+	}
+	checkState(t, raw, StateSet)
+	if !bytes.Equal(raw.MustGet(), []byte{0xff, 2}) {
+		t.Error("wrong value:", raw.MustGet())
+	}
+
+	// legacy bytes that start with the header are read as the current format
+	if err := raw.UnmarshalBinary([]byte("\xffop\x01\x02\x07\x01x")); err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(raw.MustGet(), []byte("x")) {
+		t.Error("wrong value:", raw.MustGet())
+	}
+}
+
+func TestAppendText(t *testing.T) {
+	t.Parallel()
+
+	buf := []byte("prefix:")
+	b, err := From(int64(-5)).AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:-5" {
+		t.Error("wrong value:", string(b))
+	}
+
+	b, err = FromPtr[int64](nil).AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:" {
+		t.Error("wrong value:", string(b))
+	}
+
+	b, err = From(net.IPv4(1, 1, 1, 1)).AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:1.1.1.1" {
+		t.Error("wrong value:", string(b))
+	}
+}
+
+func TestAppendTextAllocs(t *testing.T) {
+	val := From(int64(12345))
+	buf := make([]byte, 0, 32)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = val.AppendText(buf[:0])
+	})
+	if allocs != 0 {
+		t.Error("expected no allocations, got:", allocs)
+	}
+}
+
+func TestAppendBinary(t *testing.T) {
+	t.Parallel()
+
+	buf := []byte("prefix:")
+	b, err := From(1.5).AppendBinary(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:\xffop\x01\x02\x04\x3f\xf8\x00\x00\x00\x00\x00\x00" {
+		t.Error("wrong value:", b)
+	}
+
+	date := time.Date(2000, 1, 1, 2, 30, 0, 0, time.UTC)
+	b, err = From(date).AppendBinary(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	want := []byte{0xff, 'o', 'p', 1, 2, 8, 0, 0, 0, 0, 0x38, 0x6d, 0x66, 0xa8, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	if !bytes.Equal(b, want) {
+		t.Error("wrong value:", b)
+	}
+
+	for _, offset := range []int{-60, 5*3600 + 30*60 + 15} {
+		in := date.In(time.FixedZone("", offset))
+		b, err := From(in).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out Val[time.Time]
+		if err := out.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if _, got := out.MustGet().Zone(); got != offset || !out.MustGet().Equal(in) {
+			t.Errorf("want: %v got: %v", in, out.MustGet())
+		}
+	}
+}
+
+func TestMarshalEmpty(t *testing.T) {
+	t.Parallel()
+
+	// An empty set value must be distinguishable from null
+	b, err := From("").MarshalText()
+	if err != nil {
+		t.Error(err)
+	}
+	if b == nil {
+		t.Error("expected non-nil bytes")
+	}
+
+	b, err = From("").MarshalBinary()
+	if err != nil {
+		t.Error(err)
+	}
+	if b == nil {
+		t.Error("expected non-nil bytes")
+	}
 }
 
 func TestScan(t *testing.T) {
@@ -327,6 +509,14 @@ func TestScan(t *testing.T) {
 	if val.MustGet() != "hello" {
 		t.Error("wrong value")
 	}
+
+	var ts Val[time.Time]
+	if err := ts.Scan("2024-01-02 03:04:05"); err != nil {
+		t.Error(err)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !ts.MustGet().Equal(want) {
+		t.Error("wrong time:", ts.MustGet())
+	}
 }
 
 type valuerImplementation struct{}
@@ -368,6 +558,118 @@ func TestValue(t *testing.T) {
 	}
 }
 
+func TestSQL(t *testing.T) {
+	t.Parallel()
+
+	checkState(t, FromSQL(sql.Null[int]{}), StateNull)
+	val := FromSQL(sql.Null[int]{V: 5, Valid: true})
+	checkState(t, val, StateSet)
+	if val.MustGet() != 5 {
+		t.Error("wrong value")
+	}
+
+	if n := val.SQL(); n != (sql.Null[int]{V: 5, Valid: true}) {
+		t.Error("wrong sql value:", n)
+	}
+	if n := (Val[int]{}).SQL(); n.Valid {
+		t.Error("null should not be valid")
+	}
+
+	tests := []struct {
+		src   any
+		want  Val[string]
+		state state
+	}{
+		{sql.NullString{String: "a", Valid: true}, From("a"), StateSet},
+		{sql.NullString{}, Val[string]{}, StateNull},
+		{sql.NullInt64{Int64: 1, Valid: true}, From("1"), StateSet},
+		{sql.Null[string]{V: "b", Valid: true}, From("b"), StateSet},
+		{sql.Null[string]{}, Val[string]{}, StateNull},
+	}
+	for _, test := range tests {
+		var v Val[string]
+		if err := opt.ConvertAssign(&v, test.src); err != nil {
+			t.Fatal(err)
+		}
+		checkState(t, v, test.state)
+		if v != test.want {
+			t.Errorf("%#v want: %v got: %v", test.src, test.want, v)
+		}
+	}
+
+	var n sql.NullInt32
+	if err := opt.ConvertAssign(&n, From(int64(3))); err != nil {
+		t.Fatal(err)
+	}
+	if n != (sql.NullInt32{Int32: 3, Valid: true}) {
+		t.Error("wrong sql value:", n)
+	}
+	if err := opt.ConvertAssign(&n, Val[int64]{}); err != nil {
+		t.Fatal(err)
+	}
+	if n.Valid {
+		t.Error("null should not be valid")
+	}
+}
+
+func TestPGArray(t *testing.T) {
+	t.Parallel()
+
+	var ints Val[[]int64]
+	if err := ints.Scan([]byte("{1,2,3}")); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ints.MustGet(), []int64{1, 2, 3}) {
+		t.Error("wrong value:", ints.MustGet())
+	}
+	if v, err := ints.Value(); err != nil {
+		t.Error(err)
+	} else if v != "{1,2,3}" {
+		t.Error("wrong driver value:", v)
+	}
+
+	var strs Val[[]Val[string]]
+	if err := strs.Scan(`{"a b",NULL,""}`); err != nil {
+		t.Fatal(err)
+	}
+	want := []Val[string]{From("a b"), {}, From("")}
+	if !reflect.DeepEqual(strs.MustGet(), want) {
+		t.Errorf("wrong value: %#v", strs.MustGet())
+	}
+	if v, err := strs.Value(); err != nil {
+		t.Error(err)
+	} else if v != `{"a b",NULL,""}` {
+		t.Error("wrong driver value:", v)
+	}
+}
+
+func TestJSONColumn(t *testing.T) {
+	t.Parallel()
+
+	type settings struct {
+		Theme string `json:"theme"`
+	}
+
+	var val Val[opt.JSON[settings]]
+	if err := val.Scan([]byte(`{"theme":"dark"}`)); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, val, StateSet)
+	if val.MustGet().V.Theme != "dark" {
+		t.Error("wrong value:", val.MustGet())
+	}
+	if v, err := val.Value(); err != nil {
+		t.Error(err)
+	} else if v != `{"theme":"dark"}` {
+		t.Error("wrong driver value:", v)
+	}
+
+	if err := val.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, val, StateNull)
+}
+
 func TestStateStringer(t *testing.T) {
 	t.Parallel()
 
@@ -407,3 +709,28 @@ func checkJSON[T any](t *testing.T, v Val[T], s string) {
 		t.Errorf("expect: %s, got: %s", s, b)
 	}
 }
+
+func TestEqualFunc(t *testing.T) {
+	t.Parallel()
+
+	a := From([]int{1, 2})
+	b := From([]int{1, 2})
+	if !EqualFunc(a, b, slices.Equal) {
+		t.Error("should be equal")
+	}
+
+	b.Set([]int{1})
+	if EqualFunc(a, b, slices.Equal) {
+		t.Error("should not be equal")
+	}
+
+	a.Null()
+	if EqualFunc(a, b, slices.Equal) {
+		t.Error("should not be equal")
+	}
+
+	b.Null()
+	if !EqualFunc(a, b, slices.Equal) {
+		t.Error("should be equal")
+	}
+}
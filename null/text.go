@@ -0,0 +1,34 @@
+package null
+
+import (
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/decode"
+	"github.com/aarondl/opt/internal/textfmt"
+)
+
+// MarshalTextWith encodes the value as text that can be shared with other
+// systems, see opt.TextOptions. Unlike MarshalText null values are written as
+// opts.Null so they cannot be confused with an empty string.
+func (v Val[T]) MarshalTextWith(opts opt.TextOptions) ([]byte, error) {
+	if v.state != StateSet {
+		return textfmt.Null(opts), nil
+	}
+	return textfmt.Value(opts, v.value)
+}
+
+// UnmarshalTextWith reverses MarshalTextWith. Text equal to opts.Null, as well
+// as nil text (absent), makes the value null.
+func (v *Val[T]) UnmarshalTextWith(text []byte, opts opt.TextOptions) error {
+	text, null := textfmt.Parse(opts, text)
+	if null || text == nil {
+		v.Null()
+		return nil
+	}
+
+	var value T
+	if err := decode.Text(&value, text); err != nil {
+		return decode.Error[T](text, decode.StateSet, err)
+	}
+	v.Set(value)
+	return nil
+}
@@ -0,0 +1,53 @@
+package null
+
+import (
+	"testing"
+
+	"github.com/aarondl/opt"
+)
+
+func TestMarshalTextWith(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts opt.TextOptions
+		val  Val[string]
+		want []byte
+	}{
+		{"null", opt.TextNull, Val[string]{}, []byte("null")},
+		{"empty", opt.TextNull, From(""), []byte("")},
+		{"value", opt.TextNull, From("hello"), []byte("hello")},
+		{"null string", opt.TextNull, From("null"), []byte(`\null`)},
+		{"escape", opt.TextNull, From(`\n`), []byte(`\\n`)},
+		{"postgres null", opt.TextPostgres, Val[string]{}, []byte(`\N`)},
+		{"postgres null string", opt.TextPostgres, From(`\N`), []byte(`\\N`)},
+		{"empty null", opt.TextOptions{Escape: `\`}, From(""), []byte(`\`)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.val.MarshalTextWith(test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != string(test.want) {
+				t.Errorf("want: %q got: %q", test.want, b)
+			}
+
+			out := From("stale")
+			if err := out.UnmarshalTextWith(b, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			if out != test.val {
+				t.Errorf("round trip want: %#v got: %#v", test.val, out)
+			}
+		})
+	}
+
+	val := From("stale")
+	if err := val.UnmarshalTextWith(nil, opt.TextNull); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, val, StateNull)
+}
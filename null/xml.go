@@ -0,0 +1,77 @@
+package null
+
+import (
+	"encoding/xml"
+
+	"github.com/aarondl/opt/internal/appender"
+	"github.com/aarondl/opt/internal/decode"
+	"github.com/aarondl/opt/internal/globaldata"
+)
+
+// MarshalXML implements xml.Marshaler. Null values are written as an empty
+// element with xsi:nil="true" so they can be told apart from empty strings.
+func (v Val[T]) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if v.state != StateSet {
+		start.Attr = append(start.Attr, globaldata.XMLNilAttrs...)
+		return e.EncodeElement("", start)
+	}
+
+	return e.EncodeElement(v.value, start)
+}
+
+// UnmarshalXML implements xml.Unmarshaler. Elements marked with xsi:nil
+// are decoded as null.
+func (v *Val[T]) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if decode.XMLNil(start.Attr) {
+		if err := d.Skip(); err != nil {
+			return decode.Error[T](start, decode.StateNull, err)
+		}
+		var zero T
+		v.value = zero
+		v.state = StateNull
+		return nil
+	}
+
+	var value T
+	if err := d.DecodeElement(&value, &start); err != nil {
+		return decode.Error[T](start, decode.StateSet, err)
+	}
+	v.value = value
+	v.state = StateSet
+	return nil
+}
+
+// MarshalXMLAttr implements xml.MarshalerAttr. Attributes cannot represent
+// null so null values omit the attribute, a missing attribute leaves the
+// value in its zero state which is null.
+func (v Val[T]) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if v.state != StateSet {
+		return xml.Attr{}, nil
+	}
+
+	if m, ok := any(v.value).(xml.MarshalerAttr); ok {
+		return m.MarshalXMLAttr(name)
+	}
+
+	text, err := appender.Text(nil, v.value)
+	if err != nil {
+		return xml.Attr{}, err
+	}
+	return xml.Attr{Name: name, Value: string(text)}, nil
+}
+
+// UnmarshalXMLAttr implements xml.UnmarshalerAttr.
+func (v *Val[T]) UnmarshalXMLAttr(attr xml.Attr) error {
+	var value T
+	if u, ok := any(&value).(xml.UnmarshalerAttr); ok {
+		if err := u.UnmarshalXMLAttr(attr); err != nil {
+			return decode.Error[T](attr, decode.StateSet, err)
+		}
+	} else if err := decode.Text(&value, []byte(attr.Value)); err != nil {
+		return decode.Error[T](attr, decode.StateSet, err)
+	}
+
+	v.value = value
+	v.state = StateSet
+	return nil
+}
@@ -0,0 +1,79 @@
+package null
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeYAML returns an unmarshal function like the one a YAML library passes
+// to UnmarshalYAML for a document containing node, nil means ~.
+func fakeYAML(node any) func(any) error {
+	return func(out any) error {
+		ptr := reflect.ValueOf(out).Elem()
+		if node == nil {
+			ptr.Set(reflect.Zero(ptr.Type()))
+			return nil
+		}
+		nv := reflect.ValueOf(node)
+		if !nv.Type().ConvertibleTo(ptr.Type().Elem()) {
+			return errors.New("cannot unmarshal")
+		}
+		ptr.Set(reflect.New(ptr.Type().Elem()))
+		ptr.Elem().Set(nv.Convert(ptr.Type().Elem()))
+		return nil
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		val  Val[string]
+		want any
+	}{
+		{"null", Val[string]{}, nil},
+		{"empty", From(""), ""},
+		{"set", From("hello"), "hello"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.val.MarshalYAML()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("want: %#v got: %#v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	t.Parallel()
+
+	var val Val[int]
+	if err := val.UnmarshalYAML(fakeYAML(5)); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, val, StateSet)
+	if val.MustGet() != 5 {
+		t.Error("value wrong:", val.MustGet())
+	}
+
+	if err := val.UnmarshalYAML(fakeYAML(nil)); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, val, StateNull)
+	if val.GetOrZero() != 0 {
+		t.Error("value should be zero")
+	}
+
+	val = From(5)
+	if err := val.UnmarshalYAML(fakeYAML("hello")); err == nil {
+		t.Error("expected an error")
+	}
+	checkState(t, val, StateSet)
+}
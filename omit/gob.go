@@ -0,0 +1,34 @@
+package omit
+
+import (
+	"errors"
+
+	"github.com/aarondl/opt/internal/binfmt"
+	"github.com/aarondl/opt/internal/decode"
+)
+
+// GobEncode implements gob.GobEncoder. It uses the same encoding as
+// MarshalBinary which keeps the state, so every state and value survives a
+// round trip.
+func (v Val[T]) GobEncode() ([]byte, error) {
+	return v.AppendBinary(nil)
+}
+
+// GobDecode implements gob.GobDecoder, reversing GobEncode.
+func (v *Val[T]) GobDecode(b []byte) error {
+	state, value, err := binfmt.Decode[T](b)
+	if err != nil {
+		return decode.Error[T](b, decode.StateUnknown, err)
+	}
+
+	switch state {
+	case binfmt.StateNull:
+		return decode.Error[T](b, decode.StateNull, errors.New("invalid gob encoding for omit.Val, it cannot be null"))
+	case binfmt.StateUnset:
+		v.state = StateUnset
+	default:
+		v.state = StateSet
+	}
+	v.value = value
+	return nil
+}
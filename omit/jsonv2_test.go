@@ -0,0 +1,48 @@
+//go:build go1.27 && goexperiment.jsonv2
+
+package omit
+
+import (
+	json "encoding/json/v2"
+	"testing"
+)
+
+func TestJSONv2(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		A Val[int]    `json:"a,omitzero"`
+		B Val[string] `json:"b,omitzero"`
+	}
+
+	in := testStruct{A: From(5)}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":5}` {
+		t.Error("json wrong:", string(b))
+	}
+
+	b, err = json.Marshal(in, json.StringifyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":"5"}` {
+		t.Error("json wrong:", string(b))
+	}
+
+	var out testStruct
+	if err := json.Unmarshal([]byte(`{"a":"6"}`), &out, json.StringifyNumbers(true)); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out.A, StateSet)
+	checkState(t, out.B, StateUnset)
+	if out.A.MustGet() != 6 {
+		t.Errorf("values wrong: %#v", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"b":null}`), &out); err == nil {
+		t.Error("expected an error unmarshaling null")
+	}
+}
@@ -5,11 +5,13 @@ package omit
 import (
 	"bytes"
 	"database/sql/driver"
-	"encoding"
 	"errors"
 	"reflect"
 
 	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/appender"
+	"github.com/aarondl/opt/internal/binfmt"
+	"github.com/aarondl/opt/internal/decode"
 	"github.com/aarondl/opt/internal/globaldata"
 )
 
@@ -127,6 +129,20 @@ func (v Val[T]) Or(other Val[T]) Val[T] {
 	}
 }
 
+// Overlay returns other unless it is unset, in which case v is returned. This
+// is useful for layering a later change on top of an earlier one.
+//
+//	v     | other | result
+//	------------- | -------
+//	_     | set   | other
+//	_     | unset | v
+func (v Val[T]) Overlay(other Val[T]) Val[T] {
+	if other.state == StateUnset {
+		return v
+	}
+	return other
+}
+
 // Map transforms the value inside if it is set, else it returns a value of the
 // same state.
 //
@@ -187,12 +203,13 @@ func (v *Val[T]) UnmarshalJSON(data []byte) error {
 		v.state = StateUnset
 		return nil
 	case bytes.Equal(data, globaldata.JSONNull):
-		return errors.New("cannot unmarshal 'null' value into omit value")
+		return decode.Error[T](data, decode.StateNull, errors.New("cannot unmarshal 'null' value into omit value"))
 	default:
-		err := opt.JSONUnmarshal(data, &v.value)
-		if err != nil {
-			return err
+		var value T
+		if err := opt.JSONUnmarshal(data, &value); err != nil {
+			return decode.Error[T](data, decode.StateSet, err)
 		}
+		v.value = value
 		v.state = StateSet
 		return nil
 	}
@@ -258,23 +275,21 @@ func (v Val[T]) IsZero() bool {
 	return false
 }
 
+// AppendText implements encoding.TextAppender. Nothing is appended for unset
+// values.
+func (v Val[T]) AppendText(b []byte) ([]byte, error) {
+	if v.state != StateSet {
+		return b, nil
+	}
+	return appender.Text(b, v.value)
+}
+
 // MarshalText implements encoding.TextMarshaler.
 func (v Val[T]) MarshalText() ([]byte, error) {
 	if v.state != StateSet {
 		return nil, nil
 	}
-
-	refVal := reflect.ValueOf(v.value)
-	if refVal.Type().Implements(globaldata.EncodingTextMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextMarshaler)
-		return valuer.MarshalText()
-	}
-
-	var text string
-	if err := opt.ConvertAssign(&text, v.value); err != nil {
-		return nil, err
-	}
-	return []byte(text), nil
+	return v.AppendText([]byte{})
 }
 
 // UnmarshalText implements encoding.TextUnmarshaler.
@@ -286,56 +301,70 @@ func (v *Val[T]) UnmarshalText(text []byte) error {
 		return nil
 	}
 
-	refVal := reflect.ValueOf(&v.value)
-	if refVal.Type().Implements(globaldata.EncodingTextUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextUnmarshaler)
-		if err := valuer.UnmarshalText(text); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
-	}
-
-	if err := opt.ConvertAssign(&v.value, string(text)); err != nil {
-		return err
+	var value T
+	if err := decode.Text(&value, text); err != nil {
+		return decode.Error[T](text, decode.StateSet, err)
 	}
-
+	v.value = value
 	v.state = StateSet
 	return nil
 }
 
-// MarshalBinary tries to encode the value in binary. If it finds
-// type that implements encoding.BinaryMarshaler it will use that,
-// it will fallback to encoding.TextMarshaler if that is implemented,
-// and failing that it will attempt to do some reflect to convert between
-// the types to hit common cases like Go primitives.
-func (v Val[T]) MarshalBinary() ([]byte, error) {
+// AppendBinary implements encoding.BinaryAppender. See MarshalBinary for
+// details about the encoding.
+func (v Val[T]) AppendBinary(b []byte) ([]byte, error) {
 	if v.state != StateSet {
-		return nil, nil
+		return binfmt.Append(b, binfmt.StateUnset, v.value)
 	}
+	return binfmt.Append(b, binfmt.StateSet, v.value)
+}
 
-	refVal := reflect.ValueOf(v.value)
-	if refVal.Type().Implements(globaldata.EncodingBinaryMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.BinaryMarshaler)
-		return valuer.MarshalBinary()
-	}
+// MarshalBinary encodes the state and value in a compact binary format.
+// Integers are written as varints, floats as IEEE-754, strings and bytes are
+// length prefixed and time.Time has a fixed width. Other types use
+// encoding.BinaryMarshaler if they implement it and their text representation
+// (see MarshalText) if not.
+//
+// The encoding starts with a header and version followed by the state, so a set
+// empty value is always distinct from unset.
+func (v Val[T]) MarshalBinary() ([]byte, error) {
+	return v.AppendBinary(nil)
+}
 
-	if refVal.Type().Implements(globaldata.EncodingTextMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextMarshaler)
-		return valuer.MarshalText()
+// UnmarshalBinary reverses MarshalBinary. Like UnmarshalJSON it fails if the
+// data holds a null value.
+//
+// Data that does not start with the header is decoded in the legacy
+// format written by previous versions, where an empty slice was unset and
+// anything else was the binary or text encoding of the value. A legacy
+// encoding of a []byte or encoding.BinaryMarshaler value that happens to
+// start with the header ("\xffop") cannot be told apart from the current
+// format.
+func (v *Val[T]) UnmarshalBinary(b []byte) error {
+	if !binfmt.IsVersioned(b) {
+		return v.unmarshalBinaryLegacy(b)
 	}
 
-	var buf []byte
-	if err := opt.ConvertAssign(&buf, v.value); err != nil {
-		return nil, err
+	state, value, err := binfmt.Decode[T](b)
+	if err != nil {
+		return decode.Error[T](b, decode.StateUnknown, err)
 	}
 
-	return buf, nil
+	switch state {
+	case binfmt.StateNull:
+		return decode.Error[T](b, decode.StateNull, errors.New("cannot unmarshal 'null' value into omit value"))
+	case binfmt.StateUnset:
+		v.state = StateUnset
+	default:
+		v.state = StateSet
+	}
+	v.value = value
+	return nil
 }
 
-// UnmarshalBinary tries to reverse the value MarshalBinary operation.
-// See documentation there for details about supported types.
-func (v *Val[T]) UnmarshalBinary(b []byte) error {
+// unmarshalBinaryLegacy decodes the format MarshalBinary used before the
+// header was introduced.
+func (v *Val[T]) unmarshalBinaryLegacy(b []byte) error {
 	if len(b) == 0 {
 		var zero T
 		v.value = zero
@@ -343,41 +372,30 @@ func (v *Val[T]) UnmarshalBinary(b []byte) error {
 		return nil
 	}
 
-	refVal := reflect.ValueOf(&v.value)
-	if refVal.Type().Implements(globaldata.EncodingBinaryUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.BinaryUnmarshaler)
-		if err := valuer.UnmarshalBinary(b); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
-	}
-
-	if refVal.Type().Implements(globaldata.EncodingTextUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextUnmarshaler)
-		if err := valuer.UnmarshalText(b); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
-	}
-
-	if err := opt.ConvertAssign(&v.value, b); err != nil {
-		return err
+	var value T
+	if err := decode.Binary(&value, b); err != nil {
+		return decode.Error[T](b, decode.StateSet, err)
 	}
-
+	v.value = value
 	v.state = StateSet
 	return nil
 }
 
 // Scan implements the sql.Scanner interface. If the wrapped type implements
-// sql.Scanner then it will call that.
+// sql.Scanner then it will call that. Slices other than []byte are parsed
+// from Postgres arrays, see opt.ParsePGArray.
 func (v *Val[T]) Scan(value any) error {
 	if value == nil {
-		return errors.New("cannot store 'null' value in omit value")
+		return decode.Error[T](value, decode.StateNull, errors.New("cannot store 'null' value in omit value"))
 	}
+
+	var val T
+	if err := opt.ConvertAssign(&val, value); err != nil {
+		return decode.Error[T](value, decode.StateSet, err)
+	}
+	v.value = val
 	v.state = StateSet
-	return opt.ConvertAssign(&v.value, value)
+	return nil
 }
 
 // Value implements the driver.Valuer interface. If the underlying type
@@ -385,7 +403,8 @@ func (v *Val[T]) Scan(value any) error {
 // Go primitive types will be converted where possible.
 //
 // Because sql doesn't have an analog to unset it will marshal as null in these
-// cases.
+// cases. See the sqlgen package for building statements that leave unset
+// values out entirely.
 //
 //	int64
 //	float64
@@ -393,12 +412,15 @@ func (v *Val[T]) Scan(value any) error {
 //	[]byte
 //	string
 //	time.Time
+//
+// Slices other than []byte are written as Postgres arrays, see
+// opt.AppendPGArray.
 func (v Val[T]) Value() (driver.Value, error) {
 	if v.state != StateSet {
 		return nil, nil
 	}
 
-	return opt.ToDriverValue(v.value)
+	return opt.ToDriverValueArray(v.value)
 }
 
 // Equal compares two nullable values and returns true if they are equal.
@@ -414,3 +436,18 @@ func Equal[T comparable](a, b Val[T]) bool {
 
 	return a.value == b.value
 }
+
+// EqualFunc compares two omittable values and returns true if they are equal.
+// It is like Equal but uses eq to compare the values when both are set
+// which allows it to work with types that are not comparable.
+func EqualFunc[T any](a, b Val[T], eq func(T, T) bool) bool {
+	if a.state != b.state {
+		return false
+	}
+
+	if a.state != StateSet {
+		return true
+	}
+
+	return eq(a.value, b.value)
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"database/sql/driver"
 	"net"
+	"reflect"
+	"slices"
 	"testing"
 	"time"
 
@@ -100,6 +102,27 @@ func TestOr(t *testing.T) {
 	}
 }
 
+func TestOverlay(t *testing.T) {
+	t.Parallel()
+
+	var set, unset Val[int]
+	set.Set(5)
+	unset.Unset()
+
+	if set.Overlay(From(6)).MustGet() != 6 {
+		t.Error("it should have returned 6")
+	}
+	if set.Overlay(unset).MustGet() != 5 {
+		t.Error("it should have returned 5")
+	}
+	if unset.Overlay(set).MustGet() != 5 {
+		t.Error("it should have returned 5")
+	}
+	if !unset.Overlay(unset).IsUnset() {
+		t.Error("it should have returned unset")
+	}
+}
+
 func TestMap(t *testing.T) {
 	t.Parallel()
 
@@ -277,30 +300,74 @@ func TestMarshalBinary(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if string(b) != "hello" {
-		t.Error("expected hello in ascii bytes")
+	if string(b) != "\xffop\x01\x02\x06\x05hello" {
+		t.Error("wrong value:", b)
 	}
 
-	hello.Unset()
-	b, err = hello.MarshalBinary()
+	b, err = From(int64(-300)).MarshalBinary()
 	if err != nil {
 		t.Error(err)
 	}
-	if len(b) != 0 {
-		t.Error("expected empty byte slice")
+	if !bytes.Equal(b, []byte{0xff, 'o', 'p', 1, 2, 1, 0xd7, 0x04}) {
+		t.Error("wrong value:", b)
 	}
 
-	marshaller := From(net.IPv4(1, 1, 1, 1))
-	if b, err := marshaller.MarshalBinary(); err != nil {
+	unset := Val[string]{}
+	b, err = unset.MarshalBinary()
+	if err != nil {
 		t.Error(err)
-	} else if !bytes.Equal(b, []byte("1.1.1.1")) {
-		t.Error("wrong value")
+	}
+	if !bytes.Equal(b, []byte{0xff, 'o', 'p', 1, 0}) {
+		t.Error("wrong value:", b)
 	}
 }
 
 func TestUnmarshalBinary(t *testing.T) {
 	t.Parallel()
 
+	date := time.Date(2000, 1, 1, 2, 30, 0, 5, time.FixedZone("", -7*3600))
+	roundTripBinary(t, From(""))
+	roundTripBinary(t, From([]byte{}))
+	roundTripBinary(t, From(int8(-128)))
+	roundTripBinary(t, From(uint64(1<<64-1)))
+	roundTripBinary(t, From(float32(1.5)))
+	roundTripBinary(t, From(true))
+	roundTripBinary(t, From(net.IPv4(1, 1, 1, 1)))
+	roundTripBinary(t, From(date.UTC()))
+	roundTripBinary(t, From(date))
+
+	var val Val[string]
+	if err := val.UnmarshalBinary([]byte{0xff, 'o', 'p', 1, 1}); err == nil {
+		t.Error("expected an error for null")
+	}
+
+	var small Val[int8]
+	big, _ := From(1000).MarshalBinary()
+	if err := small.UnmarshalBinary(big); err == nil {
+		t.Error("expected an overflow error")
+	}
+}
+
+func roundTripBinary[T any](t *testing.T, in Val[T]) {
+	t.Helper()
+
+	b, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Val[T]
+	if err := out.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out, in.State())
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("want: %#v got: %#v", in, out)
+	}
+}
+
+func TestUnmarshalBinaryLegacy(t *testing.T) {
+	t.Parallel()
+
 	var val Val[string]
 	if err := val.UnmarshalBinary([]byte{}); err != nil {
 		t.Error(err)
@@ -328,6 +395,101 @@ func TestUnmarshalBinary(t *testing.T) {
 	if !unmarshaller.MustGet().Equal(net.IPv4(1, 1, 1, 1)) {
 		t.Error("wrong value")
 	}
+
+	var raw Val[[]byte]
+	if err := raw.UnmarshalBinary([]byte{0xff, 2}); err != nil {
+		t.Error(err)
+	}
+	checkState(t, raw, StateSet)
+	if !bytes.Equal(raw.MustGet(), []byte{0xff, 2}) {
+		t.Error("wrong value:", raw.MustGet())
+	}
+
+	// legacy bytes that start with the header are read as the current format
+	if err := raw.UnmarshalBinary([]byte("\xffop\x01\x02\x07\x01x")); err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(raw.MustGet(), []byte("x")) {
+		t.Error("wrong value:", raw.MustGet())
+	}
+}
+
+func TestAppendText(t *testing.T) {
+	t.Parallel()
+
+	buf := []byte("prefix:")
+	b, err := From(int64(-5)).AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:-5" {
+		t.Error("wrong value:", string(b))
+	}
+
+	b, err = Val[int64]{}.AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:" {
+		t.Error("wrong value:", string(b))
+	}
+
+	b, err = From(net.IPv4(1, 1, 1, 1)).AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:1.1.1.1" {
+		t.Error("wrong value:", string(b))
+	}
+}
+
+func TestAppendTextAllocs(t *testing.T) {
+	val := From(int64(12345))
+	buf := make([]byte, 0, 32)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = val.AppendText(buf[:0])
+	})
+	if allocs != 0 {
+		t.Error("expected no allocations, got:", allocs)
+	}
+}
+
+func TestAppendBinary(t *testing.T) {
+	t.Parallel()
+
+	buf := []byte("prefix:")
+	b, err := From(1.5).AppendBinary(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:\xffop\x01\x02\x04\x3f\xf8\x00\x00\x00\x00\x00\x00" {
+		t.Error("wrong value:", b)
+	}
+
+	date := time.Date(2000, 1, 1, 2, 30, 0, 0, time.UTC)
+	b, err = From(date).AppendBinary(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	want := []byte{0xff, 'o', 'p', 1, 2, 8, 0, 0, 0, 0, 0x38, 0x6d, 0x66, 0xa8, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	if !bytes.Equal(b, want) {
+		t.Error("wrong value:", b)
+	}
+
+	for _, offset := range []int{-60, 5*3600 + 30*60 + 15} {
+		in := date.In(time.FixedZone("", offset))
+		b, err := From(in).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out Val[time.Time]
+		if err := out.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if _, got := out.MustGet().Zone(); got != offset || !out.MustGet().Equal(in) {
+			t.Errorf("want: %v got: %v", in, out.MustGet())
+		}
+	}
 }
 
 func TestScan(t *testing.T) {
@@ -386,6 +548,34 @@ func TestValue(t *testing.T) {
 	}
 }
 
+func TestPGArray(t *testing.T) {
+	t.Parallel()
+
+	var ints Val[[]int64]
+	if err := ints.Scan([]byte("{1,2,3}")); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ints.MustGet(), []int64{1, 2, 3}) {
+		t.Error("wrong value:", ints.MustGet())
+	}
+	if v, err := ints.Value(); err != nil {
+		t.Error(err)
+	} else if v != "{1,2,3}" {
+		t.Error("wrong driver value:", v)
+	}
+
+	var strs Val[[]string]
+	if err := strs.Scan(`{"a b",c}`); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(strs.MustGet(), []string{"a b", "c"}) {
+		t.Error("wrong value:", strs.MustGet())
+	}
+	if err := strs.Scan(`{a,NULL}`); err == nil {
+		t.Error("expected an error for a NULL element")
+	}
+}
+
 func TestStateStringer(t *testing.T) {
 	t.Parallel()
 
@@ -450,3 +640,18 @@ func checkJSON[T any](t *testing.T, v Val[T], s string) {
 		t.Errorf("expect: %s, got: %s", s, b)
 	}
 }
+
+func TestEqualFunc(t *testing.T) {
+	t.Parallel()
+
+	a := From([]int{1, 2})
+	b := From([]int{1, 2})
+	if !EqualFunc(a, b, slices.Equal) {
+		t.Error("should be equal")
+	}
+
+	b.Set([]int{1})
+	if EqualFunc(a, b, slices.Equal) {
+		t.Error("should not be equal")
+	}
+}
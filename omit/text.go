@@ -0,0 +1,40 @@
+package omit
+
+import (
+	"errors"
+
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/decode"
+	"github.com/aarondl/opt/internal/textfmt"
+)
+
+// MarshalTextWith encodes the value as text that can be shared with other
+// systems, see opt.TextOptions. Unset values return nil which callers should
+// treat as absent.
+func (v Val[T]) MarshalTextWith(opts opt.TextOptions) ([]byte, error) {
+	if v.state != StateSet {
+		return nil, nil
+	}
+	return textfmt.Value(opts, v.value)
+}
+
+// UnmarshalTextWith reverses MarshalTextWith. Nil text (absent) makes the
+// value unset and like UnmarshalJSON it fails on text equal to opts.Null.
+func (v *Val[T]) UnmarshalTextWith(text []byte, opts opt.TextOptions) error {
+	if text == nil {
+		v.Unset()
+		return nil
+	}
+
+	text, null := textfmt.Parse(opts, text)
+	if null {
+		return decode.Error[T](text, decode.StateNull, errors.New("cannot unmarshal 'null' value into omit value"))
+	}
+
+	var value T
+	if err := decode.Text(&value, text); err != nil {
+		return decode.Error[T](text, decode.StateSet, err)
+	}
+	v.Set(value)
+	return nil
+}
@@ -0,0 +1,50 @@
+package omit
+
+import (
+	"testing"
+
+	"github.com/aarondl/opt"
+)
+
+func TestMarshalTextWith(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts opt.TextOptions
+		val  Val[string]
+		want []byte
+	}{
+		{"unset", opt.TextNull, Val[string]{}, nil},
+		{"empty", opt.TextNull, From(""), []byte("")},
+		{"value", opt.TextNull, From("hello"), []byte("hello")},
+		{"null string", opt.TextNull, From("null"), []byte(`\null`)},
+		{"escape", opt.TextNull, From(`\n`), []byte(`\\n`)},
+		{"postgres null string", opt.TextPostgres, From(`\N`), []byte(`\\N`)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.val.MarshalTextWith(test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (b == nil) != (test.want == nil) || string(b) != string(test.want) {
+				t.Errorf("want: %q got: %q", test.want, b)
+			}
+
+			out := From("stale")
+			if err := out.UnmarshalTextWith(b, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			if out != test.val {
+				t.Errorf("round trip want: %#v got: %#v", test.val, out)
+			}
+		})
+	}
+
+	var val Val[string]
+	if err := val.UnmarshalTextWith([]byte("null"), opt.TextNull); err == nil {
+		t.Error("expected an error for null")
+	}
+}
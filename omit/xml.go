@@ -0,0 +1,72 @@
+package omit
+
+import (
+	"encoding/xml"
+	"errors"
+
+	"github.com/aarondl/opt/internal/appender"
+	"github.com/aarondl/opt/internal/decode"
+)
+
+// MarshalXML implements xml.Marshaler. Unset values write nothing at all so
+// the element is omitted.
+func (v Val[T]) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if v.state != StateSet {
+		return nil
+	}
+
+	return e.EncodeElement(v.value, start)
+}
+
+// UnmarshalXML implements xml.Unmarshaler. Like UnmarshalJSON it will fail if
+// the element is marked with xsi:nil.
+func (v *Val[T]) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if decode.XMLNil(start.Attr) {
+		if err := d.Skip(); err != nil {
+			return decode.Error[T](start, decode.StateNull, err)
+		}
+		return decode.Error[T](start, decode.StateNull, errors.New("cannot unmarshal xsi:nil element into omit value"))
+	}
+
+	var value T
+	if err := d.DecodeElement(&value, &start); err != nil {
+		return decode.Error[T](start, decode.StateSet, err)
+	}
+	v.value = value
+	v.state = StateSet
+	return nil
+}
+
+// MarshalXMLAttr implements xml.MarshalerAttr. Unset values omit the
+// attribute.
+func (v Val[T]) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if v.state != StateSet {
+		return xml.Attr{}, nil
+	}
+
+	if m, ok := any(v.value).(xml.MarshalerAttr); ok {
+		return m.MarshalXMLAttr(name)
+	}
+
+	text, err := appender.Text(nil, v.value)
+	if err != nil {
+		return xml.Attr{}, err
+	}
+	return xml.Attr{Name: name, Value: string(text)}, nil
+}
+
+// UnmarshalXMLAttr implements xml.UnmarshalerAttr.
+func (v *Val[T]) UnmarshalXMLAttr(attr xml.Attr) error {
+	var value T
+	if u, ok := any(&value).(xml.UnmarshalerAttr); ok {
+		if err := u.UnmarshalXMLAttr(attr); err != nil {
+			return decode.Error[T](attr, decode.StateSet, err)
+		}
+	} else if err := decode.Text(&value, []byte(attr.Value)); err != nil {
+		return decode.Error[T](attr, decode.StateSet, err)
+	}
+
+	v.value = value
+	v.state = StateSet
+	return nil
+}
@@ -0,0 +1,47 @@
+package omit
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+type xmlDoc struct {
+	XMLName xml.Name    `xml:"doc"`
+	A       Val[int]    `xml:"a"`
+	B       Val[string] `xml:"b"`
+	C       Val[string] `xml:"c"`
+	D       Val[int]    `xml:"d,attr"`
+	E       Val[int]    `xml:"e,attr"`
+}
+
+func TestXML(t *testing.T) {
+	t.Parallel()
+
+	in := xmlDoc{A: From(5), C: From(""), D: From(6)}
+	b, err := xml.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<doc d="6"><a>5</a><c></c></doc>`
+	if string(b) != want {
+		t.Errorf("xml wrong\nwant: %s\ngot:  %s", want, b)
+	}
+
+	var out xmlDoc
+	if err := xml.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out.A, StateSet)
+	checkState(t, out.B, StateUnset)
+	checkState(t, out.C, StateSet)
+	checkState(t, out.D, StateSet)
+	checkState(t, out.E, StateUnset)
+	if out.A.MustGet() != 5 || out.C.MustGet() != "" || out.D.MustGet() != 6 {
+		t.Errorf("values wrong: %#v", out)
+	}
+
+	err = xml.Unmarshal([]byte(`<doc xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><a xsi:nil="true"/></doc>`), &out)
+	if err == nil {
+		t.Error("expected an error for xsi:nil")
+	}
+}
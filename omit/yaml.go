@@ -0,0 +1,34 @@
+package omit
+
+import (
+	"errors"
+
+	"github.com/aarondl/opt/internal/decode"
+)
+
+// MarshalYAML implements the yaml.Marshaler interface shared by the common
+// YAML libraries. Unset values marshal as null, use the omitempty flag (which
+// respects IsZero) to leave them out.
+func (v Val[T]) MarshalYAML() (any, error) {
+	if v.state != StateSet {
+		return nil, nil
+	}
+	return v.value, nil
+}
+
+// UnmarshalYAML implements the v2-style yaml.Unmarshaler interface shared by
+// the common YAML libraries. Like UnmarshalJSON it will fail on a null (~)
+// value.
+func (v *Val[T]) UnmarshalYAML(unmarshal func(any) error) error {
+	var ptr *T
+	if err := unmarshal(&ptr); err != nil {
+		return decode.Error[T](nil, decode.StateUnknown, err)
+	}
+	if ptr == nil {
+		return decode.Error[T](nil, decode.StateNull, errors.New("cannot unmarshal 'null' value into omit value"))
+	}
+
+	v.value = *ptr
+	v.state = StateSet
+	return nil
+}
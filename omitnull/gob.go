@@ -0,0 +1,32 @@
+package omitnull
+
+import (
+	"github.com/aarondl/opt/internal/binfmt"
+	"github.com/aarondl/opt/internal/decode"
+)
+
+// GobEncode implements gob.GobEncoder. It uses the same encoding as
+// MarshalBinary which keeps the state, so every state and value survives a
+// round trip.
+func (v Val[T]) GobEncode() ([]byte, error) {
+	return v.AppendBinary(nil)
+}
+
+// GobDecode implements gob.GobDecoder, reversing GobEncode.
+func (v *Val[T]) GobDecode(b []byte) error {
+	state, value, err := binfmt.Decode[T](b)
+	if err != nil {
+		return decode.Error[T](b, decode.StateUnknown, err)
+	}
+
+	switch state {
+	case binfmt.StateUnset:
+		v.state = StateUnset
+	case binfmt.StateNull:
+		v.state = StateNull
+	default:
+		v.state = StateSet
+	}
+	v.value = value
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build go1.27 && goexperiment.jsonv2
+
+package omitnull
+
+import (
+	"encoding/json/jsontext"
+	json "encoding/json/v2"
+)
+
+// MarshalJSONTo implements json.MarshalerTo from encoding/json/v2. The value is
+// written directly to the encoder using the encoder's options, so options
+// like json.StringifyNumbers apply to T as well.
+//
+// Values that are not set are written as null, use the omitzero tag option
+// to leave them out of objects instead.
+func (v Val[T]) MarshalJSONTo(enc *jsontext.Encoder) error {
+	return v.marshalJSONTo(
+		func() error { return enc.WriteToken(jsontext.Null) },
+		func(value any) error { return json.MarshalEncode(enc, value) },
+	)
+}
+
+// UnmarshalJSONFrom implements json.UnmarshalerFrom from encoding/json/v2.
+func (v *Val[T]) UnmarshalJSONFrom(dec *jsontext.Decoder) error {
+	return v.unmarshalJSONFrom(dec, dec.PeekKind() == 'n',
+		func() error {
+			_, err := dec.ReadToken()
+			return err
+		},
+		func(value any) error { return json.UnmarshalDecode(dec, value) },
+	)
+}
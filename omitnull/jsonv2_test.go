@@ -0,0 +1,52 @@
+//go:build go1.27 && goexperiment.jsonv2
+
+package omitnull
+
+import (
+	json "encoding/json/v2"
+	"testing"
+)
+
+func TestJSONv2(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		A Val[int]    `json:"a,omitzero"`
+		B Val[int]    `json:"b,omitzero"`
+		C Val[string] `json:"c"`
+		D Val[int]    `json:"d"`
+	}
+
+	in := testStruct{A: From(5), D: FromPtr[int](nil)}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":5,"c":null,"d":null}` {
+		t.Error("json wrong:", string(b))
+	}
+
+	b, err = json.Marshal(in, json.StringifyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":"5","c":null,"d":null}` {
+		t.Error("json wrong:", string(b))
+	}
+
+	var out testStruct
+	if err := json.Unmarshal([]byte(`{"a":"6","c":"hi","d":null}`), &out, json.StringifyNumbers(true)); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out.A, StateSet)
+	checkState(t, out.B, StateUnset)
+	checkState(t, out.C, StateSet)
+	checkState(t, out.D, StateNull)
+	if out.A.MustGet() != 6 || out.C.MustGet() != "hi" {
+		t.Errorf("values wrong: %#v", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":"x"}`), &out); err == nil {
+		t.Error("expected an error")
+	}
+}
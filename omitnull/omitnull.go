@@ -4,12 +4,14 @@ package omitnull
 
 import (
 	"bytes"
+	"database/sql"
 	"database/sql/driver"
-	"encoding"
 	"errors"
-	"reflect"
 
 	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/appender"
+	"github.com/aarondl/opt/internal/binfmt"
+	"github.com/aarondl/opt/internal/decode"
 	"github.com/aarondl/opt/internal/globaldata"
 	"github.com/aarondl/opt/null"
 	"github.com/aarondl/opt/omit"
@@ -83,6 +85,15 @@ func FromOmit[T any](val omit.Val[T]) Val[T] {
 	return Val[T]{}
 }
 
+// FromSQL creates a value from a database/sql.Null, it is null if the
+// sql.Null is not valid.
+func FromSQL[T any](val sql.Null[T]) Val[T] {
+	if !val.Valid {
+		return Val[T]{state: StateNull}
+	}
+	return From(val.V)
+}
+
 // Get the underlying value, if one exists.
 func (v Val[T]) Get() (T, bool) {
 	if v.state == StateSet {
@@ -191,6 +202,22 @@ func (v Val[T]) Or(other Val[T]) Val[T] {
 	}
 }
 
+// Overlay returns other unless it is unset, in which case v is returned. This
+// is useful for layering a later change on top of an earlier one where, unlike
+// Or, a later null takes precedence over an earlier set value.
+//
+//	v     | other | result
+//	------------- | -------
+//	_     | set   | other
+//	_     | null  | other
+//	_     | unset | v
+func (v Val[T]) Overlay(other Val[T]) Val[T] {
+	if other.state == StateUnset {
+		return v
+	}
+	return other
+}
+
 // Map transforms the value inside if it is set, else it returns a value of the
 // same state.
 //
@@ -260,6 +287,13 @@ func (v Val[T]) IsUnset() bool {
 	return v.state == StateUnset
 }
 
+// SQL converts the value to a database/sql.Null which is valid when v is set.
+// Because sql doesn't have an analog to unset, unset values are not valid the
+// same as null values.
+func (v Val[T]) SQL() sql.Null[T] {
+	return sql.Null[T]{V: v.value, Valid: v.state == StateSet}
+}
+
 // State retrieves the internal state, mostly useful for testing.
 func (v Val[T]) State() state {
 	return v.state
@@ -308,10 +342,11 @@ func (v *Val[T]) UnmarshalJSON(data []byte) error {
 		v.state = StateNull
 		return nil
 	default:
-		err := opt.JSONUnmarshal(data, &v.value)
-		if err != nil {
-			return err
+		var value T
+		if err := opt.JSONUnmarshal(data, &value); err != nil {
+			return decode.Error[T](data, decode.StateSet, err)
 		}
+		v.value = value
 		v.state = StateSet
 		return nil
 	}
@@ -337,6 +372,19 @@ func (v Val[T]) MarshalJSON() ([]byte, error) {
 	}
 }
 
+// AppendText implements encoding.TextAppender. See MarshalText for details
+// about the encoding.
+func (v Val[T]) AppendText(b []byte) ([]byte, error) {
+	switch v.state {
+	case StateUnset:
+		return b, nil
+	case StateNull:
+		return append(b, '0'), nil
+	}
+
+	return appender.Text(append(b, '1'), v.value)
+}
+
 // MarshalText implements encoding.TextMarshaler. If the value
 // is omitted it will return nil (empty string), if the value
 // is null it will return '0' as a representation, and if the
@@ -344,32 +392,13 @@ func (v Val[T]) MarshalJSON() ([]byte, error) {
 //
 // That's also to say that there is no compatibility with the outside
 // world. A value that is Unmarshal'd by this package must have been
-// produced by this package to encode the text properly.
+// produced by this package to encode the text properly. Use
+// MarshalTextWith for text that is shared with other systems.
 func (v Val[T]) MarshalText() ([]byte, error) {
-	switch v.state {
-	case StateUnset:
+	if v.state == StateUnset {
 		return nil, nil
-	case StateNull:
-		return []byte{'0'}, nil
-	}
-
-	out := []byte{'1'}
-
-	refVal := reflect.ValueOf(v.value)
-	if refVal.Type().Implements(globaldata.EncodingTextMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextMarshaler)
-		b, err := valuer.MarshalText()
-		if err != nil {
-			return nil, err
-		}
-		return append(out, b...), nil
-	}
-
-	var text string
-	if err := opt.ConvertAssign(&text, v.value); err != nil {
-		return nil, err
 	}
-	return append(out, text...), nil
+	return v.AppendText(nil)
 }
 
 // UnmarshalText implements encoding.TextUnmarshaler.
@@ -385,77 +414,73 @@ func (v *Val[T]) UnmarshalText(text []byte) error {
 		v.state = StateNull
 		return nil
 	} else if text[0] != '1' {
-		return errors.New("invalid text format for omitnull.Val, expected [], [0, ...], or [1, ...]")
-	}
-
-	text = text[1:]
-
-	refVal := reflect.ValueOf(&v.value)
-	if refVal.Type().Implements(globaldata.EncodingTextUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextUnmarshaler)
-		if err := valuer.UnmarshalText(text); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
+		return decode.Error[T](text, decode.StateUnknown, errors.New("invalid text format for omitnull.Val, expected [], [0, ...], or [1, ...]"))
 	}
 
-	if err := opt.ConvertAssign(&v.value, string(text)); err != nil {
-		return err
+	var value T
+	if err := decode.Text(&value, text[1:]); err != nil {
+		return decode.Error[T](text, decode.StateSet, err)
 	}
-
+	v.value = value
 	v.state = StateSet
 	return nil
 }
 
-// MarshalBinary tries to encode the value in binary. If it finds
-// type that implements encoding.BinaryMarshaler it will use that,
-// it will fallback to encoding.TextMarshaler if that is implemented,
-// and failing that it will attempt to do some reflect to convert between
-// the types to hit common cases like Go primitives.
-//
-// Omitnull will add a prepend a single byte to the value's binary
-// encoding track the state (0 for null, 1 for set) when it is not omitted.
-func (v Val[T]) MarshalBinary() ([]byte, error) {
+// AppendBinary implements encoding.BinaryAppender. See MarshalBinary for
+// details about the encoding.
+func (v Val[T]) AppendBinary(b []byte) ([]byte, error) {
 	switch v.state {
 	case StateUnset:
-		return nil, nil
+		return binfmt.Append(b, binfmt.StateUnset, v.value)
 	case StateNull:
-		return []byte{0}, nil
+		return binfmt.Append(b, binfmt.StateNull, v.value)
 	}
+	return binfmt.Append(b, binfmt.StateSet, v.value)
+}
 
-	out := []byte{1}
+// MarshalBinary encodes the state and value in a compact binary format.
+// Integers are written as varints, floats as IEEE-754, strings and bytes are
+// length prefixed and time.Time has a fixed width. Other types use
+// encoding.BinaryMarshaler if they implement it and their text representation
+// (see MarshalText) if not.
+//
+// The encoding starts with a header and version followed by the state, so a set
+// empty value is always distinct from null and unset.
+func (v Val[T]) MarshalBinary() ([]byte, error) {
+	return v.AppendBinary(nil)
+}
 
-	refVal := reflect.ValueOf(v.value)
-	if refVal.Type().Implements(globaldata.EncodingBinaryMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.BinaryMarshaler)
-		b, err := valuer.MarshalBinary()
-		if err != nil {
-			return nil, err
-		}
-		return append(out, b...), nil
+// UnmarshalBinary reverses MarshalBinary.
+//
+// Data that does not start with the header is decoded in the legacy
+// format written by previous versions, where an empty slice was unset, [0]
+// was null and anything else was 1 followed by the binary or text encoding
+// of the value.
+func (v *Val[T]) UnmarshalBinary(b []byte) error {
+	if !binfmt.IsVersioned(b) {
+		return v.unmarshalBinaryLegacy(b)
 	}
 
-	if refVal.Type().Implements(globaldata.EncodingTextMarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextMarshaler)
-		b, err := valuer.MarshalText()
-		if err != nil {
-			return nil, err
-		}
-		return append(out, b...), nil
+	state, value, err := binfmt.Decode[T](b)
+	if err != nil {
+		return decode.Error[T](b, decode.StateUnknown, err)
 	}
 
-	var buf []byte
-	if err := opt.ConvertAssign(&buf, v.value); err != nil {
-		return nil, err
+	switch state {
+	case binfmt.StateUnset:
+		v.state = StateUnset
+	case binfmt.StateNull:
+		v.state = StateNull
+	default:
+		v.state = StateSet
 	}
-
-	return append(out, buf...), nil
+	v.value = value
+	return nil
 }
 
-// UnmarshalBinary tries to reverse the value MarshalBinary operation.
-// See documentation there for details about supported types.
-func (v *Val[T]) UnmarshalBinary(b []byte) error {
+// unmarshalBinaryLegacy decodes the format MarshalBinary used before the
+// header was introduced.
+func (v *Val[T]) unmarshalBinaryLegacy(b []byte) error {
 	if len(b) == 0 {
 		var zero T
 		v.value = zero
@@ -467,40 +492,21 @@ func (v *Val[T]) UnmarshalBinary(b []byte) error {
 		v.state = StateNull
 		return nil
 	} else if b[0] != 1 {
-		return errors.New("invalid binary format for omitnull.Val, expected [], [0, ...], or [1, ...]")
-	}
-
-	b = b[1:]
-
-	refVal := reflect.ValueOf(&v.value)
-	if refVal.Type().Implements(globaldata.EncodingBinaryUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.BinaryUnmarshaler)
-		if err := valuer.UnmarshalBinary(b); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
-	}
-
-	if refVal.Type().Implements(globaldata.EncodingTextUnmarshalerIntf) {
-		valuer := refVal.Interface().(encoding.TextUnmarshaler)
-		if err := valuer.UnmarshalText(b); err != nil {
-			return err
-		}
-		v.state = StateSet
-		return nil
+		return decode.Error[T](b, decode.StateUnknown, errors.New("invalid binary format for omitnull.Val, expected [], [0, ...], or [1, ...]"))
 	}
 
-	if err := opt.ConvertAssign(&v.value, b); err != nil {
-		return err
+	var value T
+	if err := decode.Binary(&value, b[1:]); err != nil {
+		return decode.Error[T](b, decode.StateSet, err)
 	}
-
+	v.value = value
 	v.state = StateSet
 	return nil
 }
 
 // Scan implements the sql.Scanner interface. If the wrapped type implements
-// sql.Scanner then it will call that.
+// sql.Scanner then it will call that. Slices other than []byte are parsed
+// from Postgres arrays, see opt.ParsePGArray.
 func (v *Val[T]) Scan(value any) error {
 	if value == nil {
 		var zero T
@@ -508,8 +514,14 @@ func (v *Val[T]) Scan(value any) error {
 		v.state = StateNull
 		return nil
 	}
+
+	var val T
+	if err := opt.ConvertAssign(&val, value); err != nil {
+		return decode.Error[T](value, decode.StateSet, err)
+	}
+	v.value = val
 	v.state = StateSet
-	return opt.ConvertAssign(&v.value, value)
+	return nil
 }
 
 // Value implements the driver.Valuer interface. If the underlying type
@@ -522,12 +534,15 @@ func (v *Val[T]) Scan(value any) error {
 //	[]byte
 //	string
 //	time.Time
+//
+// Slices other than []byte are written as Postgres arrays, see
+// opt.AppendPGArray.
 func (v Val[T]) Value() (driver.Value, error) {
 	if v.state != StateSet {
 		return nil, nil
 	}
 
-	return opt.ToDriverValue(v.value)
+	return opt.ToDriverValueArray(v.value)
 }
 
 // Equal compares two nullable values and returns true if they are equal.
@@ -543,3 +558,18 @@ func Equal[T comparable](a, b Val[T]) bool {
 
 	return a.value == b.value
 }
+
+// EqualFunc compares two nullable values and returns true if they are equal.
+// It is like Equal but uses eq to compare the values when both are set
+// which allows it to work with types that are not comparable.
+func EqualFunc[T any](a, b Val[T], eq func(T, T) bool) bool {
+	if a.state != b.state {
+		return false
+	}
+
+	if a.state != StateSet {
+		return true
+	}
+
+	return eq(a.value, b.value)
+}
@@ -2,8 +2,11 @@ package omitnull
 
 import (
 	"bytes"
+	"database/sql"
 	"database/sql/driver"
 	"net"
+	"reflect"
+	"slices"
 	"testing"
 	"time"
 
@@ -242,6 +245,39 @@ func TestOr(t *testing.T) {
 	}
 }
 
+func TestOverlay(t *testing.T) {
+	t.Parallel()
+
+	var set, null, unset Val[int]
+	set.Set(5)
+	null.Null()
+	unset.Unset()
+
+	if set.Overlay(From(6)).MustGet() != 6 {
+		t.Error("it should have returned 6")
+	}
+	if !set.Overlay(null).IsNull() {
+		t.Error("it should have returned null")
+	}
+	if set.Overlay(unset).MustGet() != 5 {
+		t.Error("it should have returned 5")
+	}
+
+	if null.Overlay(set).MustGet() != 5 {
+		t.Error("it should have returned 5")
+	}
+	if !null.Overlay(unset).IsNull() {
+		t.Error("it should have returned null")
+	}
+
+	if !unset.Overlay(null).IsNull() {
+		t.Error("it should have returned null")
+	}
+	if !unset.Overlay(unset).IsUnset() {
+		t.Error("it should have returned unset")
+	}
+}
+
 func TestMap(t *testing.T) {
 	t.Parallel()
 
@@ -422,8 +458,16 @@ func TestMarshalBinary(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if string(b) != "\x01hello" {
-		t.Error("expected hello with a set byte")
+	if string(b) != "\xffop\x01\x02\x06\x05hello" {
+		t.Error("wrong value:", b)
+	}
+
+	b, err = From(int64(-300)).MarshalBinary()
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(b, []byte{0xff, 'o', 'p', 1, 2, 1, 0xd7, 0x04}) {
+		t.Error("wrong value:", b)
 	}
 
 	hello.Null()
@@ -431,8 +475,8 @@ func TestMarshalBinary(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if !bytes.Equal(b, []byte{0}) {
-		t.Error("expected a null byte")
+	if !bytes.Equal(b, []byte{0xff, 'o', 'p', 1, 1}) {
+		t.Error("wrong value:", b)
 	}
 
 	hello.Unset()
@@ -440,19 +484,50 @@ func TestMarshalBinary(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if len(b) != 0 {
-		t.Error("expected empty byte slice")
+	if !bytes.Equal(b, []byte{0xff, 'o', 'p', 1, 0}) {
+		t.Error("wrong value:", b)
 	}
+}
 
-	marshaller := From(net.IPv4(1, 1, 1, 1))
-	if b, err := marshaller.MarshalBinary(); err != nil {
-		t.Error(err)
-	} else if !bytes.Equal(b, []byte("\x011.1.1.1")) {
-		t.Error("wrong value")
+func TestUnmarshalBinary(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2000, 1, 1, 2, 30, 0, 5, time.FixedZone("", -7*3600))
+	roundTripBinary(t, From(""))
+	roundTripBinary(t, From([]byte{}))
+	roundTripBinary(t, From(int8(-128)))
+	roundTripBinary(t, From(uint64(1<<64-1)))
+	roundTripBinary(t, From(float32(1.5)))
+	roundTripBinary(t, From(true))
+	roundTripBinary(t, From(net.IPv4(1, 1, 1, 1)))
+	roundTripBinary(t, From(date.UTC()))
+	roundTripBinary(t, From(date))
+
+	var small Val[int8]
+	big, _ := From(1000).MarshalBinary()
+	if err := small.UnmarshalBinary(big); err == nil {
+		t.Error("expected an overflow error")
 	}
 }
 
-func TestUnmarshalBinary(t *testing.T) {
+func roundTripBinary[T any](t *testing.T, in Val[T]) {
+	t.Helper()
+
+	b, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Val[T]
+	if err := out.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out, in.State())
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("want: %#v got: %#v", in, out)
+	}
+}
+
+func TestUnmarshalBinaryLegacy(t *testing.T) {
 	t.Parallel()
 
 	var val Val[string]
@@ -489,6 +564,92 @@ func TestUnmarshalBinary(t *testing.T) {
 	}
 }
 
+func TestAppendText(t *testing.T) {
+	t.Parallel()
+
+	buf := []byte("prefix:")
+	b, err := From(int64(-5)).AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:1-5" {
+		t.Error("wrong value:", string(b))
+	}
+
+	b, err = FromPtr[int64](nil).AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:0" {
+		t.Error("wrong value:", string(b))
+	}
+
+	b, err = Val[int64]{}.AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:" {
+		t.Error("wrong value:", string(b))
+	}
+
+	b, err = From(net.IPv4(1, 1, 1, 1)).AppendText(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:11.1.1.1" {
+		t.Error("wrong value:", string(b))
+	}
+}
+
+func TestAppendTextAllocs(t *testing.T) {
+	val := From(int64(12345))
+	buf := make([]byte, 0, 32)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = val.AppendText(buf[:0])
+	})
+	if allocs != 0 {
+		t.Error("expected no allocations, got:", allocs)
+	}
+}
+
+func TestAppendBinary(t *testing.T) {
+	t.Parallel()
+
+	buf := []byte("prefix:")
+	b, err := From(1.5).AppendBinary(buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "prefix:\xffop\x01\x02\x04\x3f\xf8\x00\x00\x00\x00\x00\x00" {
+		t.Error("wrong value:", b)
+	}
+
+	date := time.Date(2000, 1, 1, 2, 30, 0, 0, time.UTC)
+	b, err = From(date).AppendBinary(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	want := []byte{0xff, 'o', 'p', 1, 2, 8, 0, 0, 0, 0, 0x38, 0x6d, 0x66, 0xa8, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	if !bytes.Equal(b, want) {
+		t.Error("wrong value:", b)
+	}
+
+	for _, offset := range []int{-60, 5*3600 + 30*60 + 15} {
+		in := date.In(time.FixedZone("", offset))
+		b, err := From(in).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out Val[time.Time]
+		if err := out.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if _, got := out.MustGet().Zone(); got != offset || !out.MustGet().Equal(in) {
+			t.Errorf("want: %v got: %v", in, out.MustGet())
+		}
+	}
+}
+
 func TestScan(t *testing.T) {
 	t.Parallel()
 
@@ -546,6 +707,76 @@ func TestValue(t *testing.T) {
 	}
 }
 
+func TestSQL(t *testing.T) {
+	t.Parallel()
+
+	checkState(t, FromSQL(sql.Null[int]{}), StateNull)
+	val := FromSQL(sql.Null[int]{V: 5, Valid: true})
+	checkState(t, val, StateSet)
+	if val.MustGet() != 5 {
+		t.Error("wrong value")
+	}
+
+	if n := val.SQL(); n != (sql.Null[int]{V: 5, Valid: true}) {
+		t.Error("wrong sql value:", n)
+	}
+	if n := FromPtr[int](nil).SQL(); n.Valid {
+		t.Error("null should not be valid")
+	}
+	if n := (Val[int]{}).SQL(); n.Valid {
+		t.Error("unset should not be valid")
+	}
+
+	var v Val[int16]
+	if err := opt.ConvertAssign(&v, sql.NullInt16{Int16: 2, Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, v, StateSet)
+	if err := opt.ConvertAssign(&v, sql.NullInt16{}); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, v, StateNull)
+
+	var n sql.NullString
+	if err := opt.ConvertAssign(&n, From("x")); err != nil {
+		t.Fatal(err)
+	}
+	if n != (sql.NullString{String: "x", Valid: true}) {
+		t.Error("wrong sql value:", n)
+	}
+}
+
+func TestPGArray(t *testing.T) {
+	t.Parallel()
+
+	var ints Val[[]int64]
+	if err := ints.Scan([]byte("{1,2,3}")); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ints.MustGet(), []int64{1, 2, 3}) {
+		t.Error("wrong value:", ints.MustGet())
+	}
+	if v, err := ints.Value(); err != nil {
+		t.Error(err)
+	} else if v != "{1,2,3}" {
+		t.Error("wrong driver value:", v)
+	}
+
+	var strs Val[[]null.Val[string]]
+	if err := strs.Scan(`{"a b",NULL,""}`); err != nil {
+		t.Fatal(err)
+	}
+	want := []null.Val[string]{null.From("a b"), {}, null.From("")}
+	if !reflect.DeepEqual(strs.MustGet(), want) {
+		t.Errorf("wrong value: %#v", strs.MustGet())
+	}
+	if v, err := strs.Value(); err != nil {
+		t.Error(err)
+	} else if v != `{"a b",NULL,""}` {
+		t.Error("wrong driver value:", v)
+	}
+}
+
 func TestStateStringer(t *testing.T) {
 	t.Parallel()
 
@@ -628,3 +859,28 @@ func checkJSON[T any](t *testing.T, v Val[T], s string) {
 		t.Errorf("expect: %s, got: %s", s, b)
 	}
 }
+
+func TestEqualFunc(t *testing.T) {
+	t.Parallel()
+
+	a := From([]int{1, 2})
+	b := From([]int{1, 2})
+	if !EqualFunc(a, b, slices.Equal) {
+		t.Error("should be equal")
+	}
+
+	b.Set([]int{1})
+	if EqualFunc(a, b, slices.Equal) {
+		t.Error("should not be equal")
+	}
+
+	a.Null()
+	if EqualFunc(a, b, slices.Equal) {
+		t.Error("should not be equal")
+	}
+
+	b.Null()
+	if !EqualFunc(a, b, slices.Equal) {
+		t.Error("should be equal")
+	}
+}
@@ -2,6 +2,8 @@ package omitnull
 
 import (
 	"bytes"
+	"encoding/gob"
+	"reflect"
 	"testing"
 )
 
@@ -535,3 +537,185 @@ func TestPrimitives(t *testing.T) {
 		}
 	})
 }
+
+func TestGobPrimitives(t *testing.T) {
+	t.Parallel()
+
+	t.Run("int8", func(t *testing.T) {
+		for _, in := range []Val[int8]{{}, FromPtr[int8](nil), From[int8](0), From[int8](-5)} {
+			var out Val[int8]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("int16", func(t *testing.T) {
+		for _, in := range []Val[int16]{{}, FromPtr[int16](nil), From[int16](0), From[int16](-5)} {
+			var out Val[int16]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("int32", func(t *testing.T) {
+		for _, in := range []Val[int32]{{}, FromPtr[int32](nil), From[int32](0), From[int32](-5)} {
+			var out Val[int32]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("int64", func(t *testing.T) {
+		for _, in := range []Val[int64]{{}, FromPtr[int64](nil), From[int64](0), From[int64](-5)} {
+			var out Val[int64]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("int", func(t *testing.T) {
+		for _, in := range []Val[int]{{}, FromPtr[int](nil), From(0), From(-5)} {
+			var out Val[int]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("uint8", func(t *testing.T) {
+		for _, in := range []Val[uint8]{{}, FromPtr[uint8](nil), From[uint8](0), From[uint8](5)} {
+			var out Val[uint8]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("uint16", func(t *testing.T) {
+		for _, in := range []Val[uint16]{{}, FromPtr[uint16](nil), From[uint16](0), From[uint16](5)} {
+			var out Val[uint16]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("uint32", func(t *testing.T) {
+		for _, in := range []Val[uint32]{{}, FromPtr[uint32](nil), From[uint32](0), From[uint32](5)} {
+			var out Val[uint32]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("uint64", func(t *testing.T) {
+		for _, in := range []Val[uint64]{{}, FromPtr[uint64](nil), From[uint64](0), From[uint64](5)} {
+			var out Val[uint64]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("uint", func(t *testing.T) {
+		for _, in := range []Val[uint]{{}, FromPtr[uint](nil), From[uint](0), From[uint](5)} {
+			var out Val[uint]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("float32", func(t *testing.T) {
+		for _, in := range []Val[float32]{{}, FromPtr[float32](nil), From[float32](0), From[float32](1.5)} {
+			var out Val[float32]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("float64", func(t *testing.T) {
+		for _, in := range []Val[float64]{{}, FromPtr[float64](nil), From(0.0), From(1.5)} {
+			var out Val[float64]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("string", func(t *testing.T) {
+		for _, in := range []Val[string]{{}, FromPtr[string](nil), From(""), From("hello")} {
+			var out Val[string]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("[]byte", func(t *testing.T) {
+		for _, in := range []Val[[]byte]{{}, FromPtr[[]byte](nil), From([]byte{}), From([]byte("hello"))} {
+			var out Val[[]byte]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("bool", func(t *testing.T) {
+		for _, in := range []Val[bool]{{}, FromPtr[bool](nil), From(false), From(true)} {
+			var out Val[bool]
+			gobRoundTrip(t, in, &out)
+			checkState(t, out, in.State())
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+	t.Run("struct field", func(t *testing.T) {
+		type wrapper struct {
+			Val   Val[int]
+			After int
+		}
+
+		for _, in := range []Val[int]{{}, FromPtr[int](nil), From(5)} {
+			var out wrapper
+			gobRoundTrip(t, wrapper{Val: in, After: 1}, &out)
+			checkState(t, out.Val, in.State())
+			if out.Val != in || out.After != 1 {
+				t.Errorf("value wrong, want: %#v got: %#v", in, out)
+			}
+		}
+	})
+}
+
+func gobRoundTrip(t *testing.T, in, out any) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	if err := gob.NewDecoder(buf).Decode(out); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,42 @@
+package omitnull
+
+import (
+	"github.com/aarondl/opt"
+	"github.com/aarondl/opt/internal/decode"
+	"github.com/aarondl/opt/internal/textfmt"
+)
+
+// MarshalTextWith encodes the value as text that can be shared with other
+// systems, see opt.TextOptions. Null values are written as opts.Null and unset
+// values return nil which callers should treat as absent.
+func (v Val[T]) MarshalTextWith(opts opt.TextOptions) ([]byte, error) {
+	switch v.state {
+	case StateUnset:
+		return nil, nil
+	case StateNull:
+		return textfmt.Null(opts), nil
+	}
+	return textfmt.Value(opts, v.value)
+}
+
+// UnmarshalTextWith reverses MarshalTextWith. Text equal to opts.Null makes the
+// value null and nil text (absent) makes it unset.
+func (v *Val[T]) UnmarshalTextWith(text []byte, opts opt.TextOptions) error {
+	if text == nil {
+		v.Unset()
+		return nil
+	}
+
+	text, null := textfmt.Parse(opts, text)
+	if null {
+		v.Null()
+		return nil
+	}
+
+	var value T
+	if err := decode.Text(&value, text); err != nil {
+		return decode.Error[T](text, decode.StateSet, err)
+	}
+	v.Set(value)
+	return nil
+}
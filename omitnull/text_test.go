@@ -0,0 +1,78 @@
+package omitnull
+
+import (
+	"testing"
+
+	"github.com/aarondl/opt"
+)
+
+func TestMarshalTextWith(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts opt.TextOptions
+		val  Val[string]
+		want []byte
+	}{
+		{"unset", opt.TextNull, Val[string]{}, nil},
+		{"null", opt.TextNull, FromPtr[string](nil), []byte("null")},
+		{"empty", opt.TextNull, From(""), []byte("")},
+		{"value", opt.TextNull, From("hello"), []byte("hello")},
+		{"null string", opt.TextNull, From("null"), []byte(`\null`)},
+		{"escape", opt.TextNull, From(`\n`), []byte(`\\n`)},
+		{"postgres null", opt.TextPostgres, FromPtr[string](nil), []byte(`\N`)},
+		{"postgres null string", opt.TextPostgres, From(`\N`), []byte(`\\N`)},
+		{"sql", opt.TextSQL, From("null"), []byte("null")},
+		{"empty null", opt.TextOptions{Escape: `\`}, From(""), []byte(`\`)},
+		{"empty null null", opt.TextOptions{Escape: `\`}, FromPtr[string](nil), []byte("")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.val.MarshalTextWith(test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (b == nil) != (test.want == nil) || string(b) != string(test.want) {
+				t.Errorf("want: %q got: %q", test.want, b)
+			}
+
+			var out Val[string]
+			if err := out.UnmarshalTextWith(b, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			if out != test.val {
+				t.Errorf("round trip want: %#v got: %#v", test.val, out)
+			}
+		})
+	}
+}
+
+func TestTextWithNoEscape(t *testing.T) {
+	t.Parallel()
+
+	opts := opt.TextOptions{Null: "null"}
+	if _, err := From("null").MarshalTextWith(opts); err == nil {
+		t.Error("expected an error when the value collides with null")
+	}
+
+	b, err := From(`\x`).MarshalTextWith(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `\x` {
+		t.Errorf("want: %q got: %q", `\x`, b)
+	}
+
+	var val Val[int]
+	if err := val.UnmarshalTextWith([]byte("5"), opts); err != nil {
+		t.Fatal(err)
+	}
+	if val.MustGet() != 5 {
+		t.Error("value wrong:", val.MustGet())
+	}
+	if err := val.UnmarshalTextWith([]byte("x"), opts); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,77 @@
+package omitnull
+
+import (
+	"encoding/xml"
+	"net"
+	"testing"
+)
+
+type xmlDoc struct {
+	XMLName xml.Name    `xml:"doc"`
+	A       Val[int]    `xml:"a"`
+	B       Val[string] `xml:"b"`
+	C       Val[string] `xml:"c"`
+	D       Val[int]    `xml:"d,attr"`
+	E       Val[net.IP] `xml:"e,attr"`
+}
+
+func TestXML(t *testing.T) {
+	t.Parallel()
+
+	in := xmlDoc{
+		A: From(5),
+		B: FromPtr[string](nil),
+		D: From(6),
+		E: From(net.IPv4(1, 1, 1, 1)),
+	}
+	b, err := xml.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<doc d="6" e="1.1.1.1"><a>5</a>` +
+		`<b xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:nil="true"></b></doc>`
+	if string(b) != want {
+		t.Errorf("xml wrong\nwant: %s\ngot:  %s", want, b)
+	}
+
+	var out xmlDoc
+	if err := xml.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out.A, StateSet)
+	checkState(t, out.B, StateNull)
+	checkState(t, out.C, StateUnset)
+	checkState(t, out.D, StateSet)
+	checkState(t, out.E, StateSet)
+	if out.A.MustGet() != 5 || out.D.MustGet() != 6 || !out.E.MustGet().Equal(net.IPv4(1, 1, 1, 1)) {
+		t.Errorf("values wrong: %#v", out)
+	}
+
+	// An empty string is not null
+	in = xmlDoc{B: From("")}
+	if b, err = xml.Marshal(in); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `<doc><b></b></doc>` {
+		t.Error("xml wrong:", string(b))
+	}
+	out = xmlDoc{}
+	if err := xml.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out.B, StateSet)
+	if out.B.MustGet() != "" {
+		t.Error("value wrong")
+	}
+
+	// Undeclared prefix
+	out = xmlDoc{}
+	if err := xml.Unmarshal([]byte(`<doc><a xsi:nil="true"/></doc>`), &out); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, out.A, StateNull)
+
+	if err := xml.Unmarshal([]byte(`<doc d="x"></doc>`), &out); err == nil {
+		t.Error("expected an error")
+	}
+}
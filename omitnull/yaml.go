@@ -0,0 +1,33 @@
+package omitnull
+
+import "github.com/aarondl/opt/internal/decode"
+
+// MarshalYAML implements the yaml.Marshaler interface shared by the common
+// YAML libraries. Null values marshal as null, as do unset values unless the
+// field has the omitempty flag which respects IsZero and leaves them out.
+func (v Val[T]) MarshalYAML() (any, error) {
+	if v.state != StateSet {
+		return nil, nil
+	}
+	return v.value, nil
+}
+
+// UnmarshalYAML implements the v2-style yaml.Unmarshaler interface shared by
+// the common YAML libraries. A null (~) value sets the null state, keys that
+// are missing from the document are never unmarshaled and stay unset.
+func (v *Val[T]) UnmarshalYAML(unmarshal func(any) error) error {
+	var ptr *T
+	if err := unmarshal(&ptr); err != nil {
+		return decode.Error[T](nil, decode.StateUnknown, err)
+	}
+	if ptr == nil {
+		var zero T
+		v.value = zero
+		v.state = StateNull
+		return nil
+	}
+
+	v.value = *ptr
+	v.state = StateSet
+	return nil
+}
@@ -0,0 +1,95 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// Compose squashes a sequence of patches into a single patch that has the
+// same effect as applying each of them in order. Each field is combined with
+// the Val's Overlay method so later set and null states override earlier
+// ones and unset fields fall through to the earlier patches.
+//
+// Fields of P that are not one of the Val types are taken from the last
+// patch.
+func Compose[P any](patches ...P) P {
+	var result P
+	if len(patches) == 0 {
+		return result
+	}
+
+	rv := reflect.ValueOf(&result).Elem()
+	rv.Set(reflect.ValueOf(patches[0]))
+	if rv.Kind() != reflect.Struct {
+		return patches[len(patches)-1]
+	}
+
+	fields := optreflect.Fields(rv.Type(), TagName)
+	for _, p := range patches[1:] {
+		pv := reflect.ValueOf(p)
+		for _, f := range fields {
+			pField, ok := f.Value(pv)
+			if !ok {
+				continue
+			}
+
+			field := f.Alloc(rv)
+			if optreflect.KindOf(f.Type) == optreflect.KindNone {
+				field.Set(pField)
+				continue
+			}
+
+			overlay := field.MethodByName("Overlay")
+			field.Set(overlay.Call([]reflect.Value{pField})[0])
+		}
+	}
+
+	return result
+}
+
+// Invert creates the patch that undoes p once it has been applied to model.
+// model must be the state before p was applied. Each field that p changes is
+// set back to model's value (or null), all other fields are left unset.
+//
+// Fields are matched between P and M the same way as Diff.
+func Invert[P any, M any](model M, p P) (P, error) {
+	var result P
+
+	rv := reflect.ValueOf(&result).Elem()
+	if rv.Kind() != reflect.Struct {
+		return result, errors.New("patch: patch type must be a struct")
+	}
+	mv := reflect.Indirect(reflect.ValueOf(model))
+	if mv.Kind() != reflect.Struct {
+		return result, errors.New("patch: model must be a struct or pointer to a struct")
+	}
+	pv := reflect.ValueOf(p)
+
+	modelFields := optreflect.Fields(mv.Type(), TagName)
+	for _, pf := range optreflect.Fields(rv.Type(), TagName) {
+		if optreflect.KindOf(pf.Type) == optreflect.KindNone {
+			continue
+		}
+		if pField, ok := pf.Value(pv); !ok || optreflect.StateOf(pField) == optreflect.StateUnset {
+			continue
+		}
+
+		mf, ok := optreflect.FieldByName(modelFields, pf.Name)
+		if !ok {
+			return result, &FieldError{Field: pf.Name, Src: pf.Type, Err: ErrNoField}
+		}
+
+		mField, _ := mf.Value(mv)
+		state, val := optreflect.ModelState(mField)
+		if state == optreflect.StateSet && !val.Type().AssignableTo(optreflect.ElemType(pf.Type)) {
+			return result, &FieldError{Field: pf.Name, Src: pf.Type, Dst: mf.Type, Err: ErrTypeMismatch}
+		}
+		if !optreflect.SetState(pf.Alloc(rv), state, val) {
+			return result, &FieldError{Field: pf.Name, Src: pf.Type, Dst: mf.Type, Err: ErrNotNullable}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type composePatch struct {
+	Version int
+	Name    omit.Val[string]
+	Age     omitnull.Val[int]
+	Email   omitnull.Val[string]
+}
+
+func TestCompose(t *testing.T) {
+	t.Parallel()
+
+	first := composePatch{Version: 1, Name: omit.From("a"), Age: omitnull.From(5)}
+	second := composePatch{Version: 2, Age: omitnull.FromPtr[int](nil)}
+	third := composePatch{Version: 3, Email: omitnull.From("c@example.com")}
+
+	p := Compose(first, second, third)
+	if p.Version != 3 {
+		t.Error("plain fields should come from the last patch")
+	}
+	if p.Name.MustGet() != "a" {
+		t.Error("name should fall through")
+	}
+	if !p.Age.IsNull() {
+		t.Error("a later null should override an earlier set")
+	}
+	if p.Email.MustGet() != "c@example.com" {
+		t.Error("email wrong")
+	}
+
+	if p := Compose[composePatch](); p.Version != 0 || !p.Name.IsUnset() {
+		t.Error("expected the zero patch")
+	}
+	if Compose(1, 2, 3) != 3 {
+		t.Error("expected the last non-struct")
+	}
+}
+
+func TestInvert(t *testing.T) {
+	t.Parallel()
+
+	type model struct {
+		Name  string
+		Age   *int
+		Email string
+	}
+
+	m := model{Name: "a", Email: "a@example.com"}
+	p := composePatch{Name: omit.From("b"), Age: omitnull.From(5)}
+
+	inverse, err := Invert(m, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inverse.Name.MustGet() != "a" {
+		t.Error("name wrong")
+	}
+	if !inverse.Age.IsNull() {
+		t.Error("age should be null")
+	}
+	if !inverse.Email.IsUnset() {
+		t.Error("email should be unset")
+	}
+
+	// Apply and undo
+	changed := m
+	if err := Apply(&changed, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(&changed, inverse); err != nil {
+		t.Fatal(err)
+	}
+	if changed != m {
+		t.Errorf("undo did not restore the model: %#v", changed)
+	}
+
+	type omitModel struct {
+		Name *string
+	}
+	_, err = Invert(omitModel{}, composePatch{Name: omit.From("b")})
+	if !errors.Is(err, ErrNotNullable) {
+		t.Error("expected not nullable error, got:", err)
+	}
+	_, err = Invert(struct{ Name int }{}, composePatch{Name: omit.From("b")})
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Error("expected type mismatch error, got:", err)
+	}
+	_, err = Invert(struct{}{}, composePatch{Name: omit.From("b")})
+	if !errors.Is(err, ErrNoField) {
+		t.Error("expected no field error, got:", err)
+	}
+}
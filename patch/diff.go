@@ -0,0 +1,125 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/aarondl/opt/internal/optreflect"
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omitnull"
+)
+
+// DiffValue returns an unset value if before and after are equal and a set
+// value of after if they differ.
+func DiffValue[T comparable](before, after T) omitnull.Val[T] {
+	if before == after {
+		return omitnull.Val[T]{}
+	}
+	return omitnull.From(after)
+}
+
+// DiffPtr compares the values pointed to by before and after. A nil pointer
+// is considered null.
+func DiffPtr[T comparable](before, after *T) omitnull.Val[T] {
+	return diff(omitnull.FromPtr(before), omitnull.FromPtr(after))
+}
+
+// DiffNull compares two nullable values.
+func DiffNull[T comparable](before, after null.Val[T]) omitnull.Val[T] {
+	return diff(omitnull.FromNull(before), omitnull.FromNull(after))
+}
+
+func diff[T comparable](before, after omitnull.Val[T]) omitnull.Val[T] {
+	if omitnull.Equal(before, after) {
+		return omitnull.Val[T]{}
+	}
+	return after
+}
+
+// Diff compares the structs before and after and returns a patch P that
+// would turn before into after when used with Apply.
+//
+// Each field of P that is one of the Val types is matched to a field of M
+// using the same naming rules as Apply. The model's fields may be plain
+// values, pointers (where nil is null) or any of the Val types. A patch
+// field is left unset when the model field did not change, becomes null when
+// the value became null and is set to the new value otherwise.
+//
+// Values are compared with == where possible. Types with an Equal method
+// that takes their own type (like time.Time) use it, and all other types
+// such as slices and maps are compared with reflect.DeepEqual.
+func Diff[P any, M any](before, after M) (P, error) {
+	var p P
+
+	pv := reflect.ValueOf(&p).Elem()
+	if pv.Kind() != reflect.Struct {
+		return p, errors.New("patch: patch type must be a struct")
+	}
+	bv := reflect.Indirect(reflect.ValueOf(before))
+	av := reflect.Indirect(reflect.ValueOf(after))
+	if bv.Kind() != reflect.Struct || av.Kind() != reflect.Struct {
+		return p, errors.New("patch: model must be a struct or pointer to a struct")
+	}
+
+	modelFields := optreflect.Fields(bv.Type(), TagName)
+	for _, pf := range optreflect.Fields(pv.Type(), TagName) {
+		kind := optreflect.KindOf(pf.Type)
+		if kind == optreflect.KindNone {
+			continue
+		}
+
+		mf, ok := optreflect.FieldByName(modelFields, pf.Name)
+		if !ok {
+			return p, &FieldError{Field: pf.Name, Src: pf.Type, Err: ErrNoField}
+		}
+
+		before, _ := mf.Value(bv)
+		after, _ := mf.Value(av)
+		beforeState, beforeVal := optreflect.ModelState(before)
+		afterState, afterVal := optreflect.ModelState(after)
+		if beforeState == afterState &&
+			(afterState != optreflect.StateSet || equal(beforeVal, afterVal)) {
+			continue
+		}
+
+		field := pf.Alloc(pv)
+		switch afterState {
+		case optreflect.StateUnset:
+			continue
+		case optreflect.StateNull:
+			if !kind.CanNull() {
+				return p, &FieldError{Field: pf.Name, Src: pf.Type, Dst: mf.Type, Err: ErrNotNullable}
+			}
+			optreflect.SetNull(field)
+		case optreflect.StateSet:
+			if !afterVal.Type().AssignableTo(optreflect.ElemType(pf.Type)) {
+				return p, &FieldError{Field: pf.Name, Src: pf.Type, Dst: mf.Type, Err: ErrTypeMismatch}
+			}
+			optreflect.Set(field, afterVal)
+		}
+	}
+
+	return p, nil
+}
+
+// equal compares two values of the same type, see Diff for details.
+func equal(a, b reflect.Value) bool {
+	t := a.Type()
+	if m, ok := t.MethodByName("Equal"); ok &&
+		m.Type.NumIn() == 2 && m.Type.In(1) == t &&
+		m.Type.NumOut() == 1 && m.Type.Out(0).Kind() == reflect.Bool {
+		return a.Method(m.Index).Call([]reflect.Value{b})[0].Bool()
+	}
+
+	switch t.Kind() {
+	case reflect.Interface, reflect.Struct, reflect.Array:
+		// These may contain values that are not comparable which would cause
+		// a panic, so defer to DeepEqual below.
+	default:
+		if t.Comparable() {
+			return a.Equal(b)
+		}
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
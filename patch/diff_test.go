@@ -0,0 +1,153 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+func TestDiffHelpers(t *testing.T) {
+	t.Parallel()
+
+	if !DiffValue(5, 5).IsUnset() {
+		t.Error("should be unset")
+	}
+	if DiffValue(5, 6).MustGet() != 6 {
+		t.Error("should be 6")
+	}
+
+	a, b := 5, 5
+	if !DiffPtr(&a, &b).IsUnset() {
+		t.Error("should be unset")
+	}
+	if !DiffPtr[int](nil, nil).IsUnset() {
+		t.Error("should be unset")
+	}
+	if !DiffPtr(&a, nil).IsNull() {
+		t.Error("should be null")
+	}
+	if DiffPtr(nil, &b).MustGet() != 5 {
+		t.Error("should be 5")
+	}
+
+	if !DiffNull(null.From(1), null.From(1)).IsUnset() {
+		t.Error("should be unset")
+	}
+	if !DiffNull(null.From(1), null.Val[int]{}).IsNull() {
+		t.Error("should be null")
+	}
+	if DiffNull(null.From(1), null.From(2)).MustGet() != 2 {
+		t.Error("should be 2")
+	}
+}
+
+type diffModel struct {
+	Name    string
+	Age     *int
+	Email   null.Val[string]
+	Tags    []string
+	Created time.Time
+	Extra   map[string]int `patch:"extra"`
+}
+
+type diffPatch struct {
+	Name    omitnull.Val[string]
+	Age     omitnull.Val[int]
+	Email   omitnull.Val[string]
+	Tags    omitnull.Val[[]string]
+	Created omitnull.Val[time.Time]
+	More    omitnull.Val[map[string]int] `patch:"extra"`
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	age := 5
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := diffModel{
+		Name:    "a",
+		Age:     &age,
+		Email:   null.From("a@example.com"),
+		Tags:    []string{"x"},
+		Created: now,
+		Extra:   map[string]int{"a": 1},
+	}
+	after := before
+	after.Tags = []string{"x"}
+	after.Created = now.In(time.FixedZone("elsewhere", 3600))
+	after.Extra = map[string]int{"a": 1}
+
+	p, err := Diff[diffPatch](before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Name.IsUnset() || !p.Age.IsUnset() || !p.Email.IsUnset() ||
+		!p.Tags.IsUnset() || !p.Created.IsUnset() || !p.More.IsUnset() {
+		t.Errorf("expected everything to be unset: %#v", p)
+	}
+
+	after.Name = "b"
+	after.Age = nil
+	after.Email = null.Val[string]{}
+	after.Tags = []string{"y"}
+	after.Extra = nil
+
+	p, err = Diff[diffPatch](&before, &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name.MustGet() != "b" {
+		t.Error("name should be b")
+	}
+	if !p.Age.IsNull() {
+		t.Error("age should be null")
+	}
+	if !p.Email.IsNull() {
+		t.Error("email should be null")
+	}
+	if tags := p.Tags.MustGet(); len(tags) != 1 || tags[0] != "y" {
+		t.Error("tags wrong:", tags)
+	}
+	if !p.Created.IsUnset() {
+		t.Error("created should be unset")
+	}
+	if p.More.MustGet() != nil {
+		t.Error("extra should be set to nil")
+	}
+
+	// Applying the diff should bring the models together
+	if err := Apply(&before, p); err != nil {
+		t.Fatal(err)
+	}
+	if before.Name != "b" || before.Age != nil || !before.Email.IsNull() || before.Tags[0] != "y" {
+		t.Errorf("apply did not reproduce the model: %#v", before)
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	t.Parallel()
+
+	type model struct {
+		Name *string
+	}
+
+	name := "a"
+	_, err := Diff[struct{ Name omit.Val[string] }](model{Name: &name}, model{})
+	if !errors.Is(err, ErrNotNullable) {
+		t.Error("expected not nullable error, got:", err)
+	}
+
+	_, err = Diff[struct{ Name omitnull.Val[int] }](model{}, model{Name: &name})
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Error("expected type mismatch error, got:", err)
+	}
+
+	_, err = Diff[struct{ Other omitnull.Val[int] }](model{}, model{})
+	if !errors.Is(err, ErrNoField) {
+		t.Error("expected no field error, got:", err)
+	}
+}
@@ -0,0 +1,228 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/aarondl/opt/internal/optreflect"
+	"github.com/aarondl/opt/omitnull"
+)
+
+// Resolution is the outcome chosen for a conflict
+type Resolution int
+
+const (
+	// Skip leaves the field unset in the merged patch, or set to the base
+	// value when the field's Val type cannot be unset
+	Skip Resolution = iota
+	// Ours takes the change from the first patch
+	Ours
+	// Theirs takes the change from the second patch
+	Theirs
+)
+
+// String -er interface implementation
+func (r Resolution) String() string {
+	switch r {
+	case Skip:
+		return "skip"
+	case Ours:
+		return "ours"
+	case Theirs:
+		return "theirs"
+	default:
+		panic("unknown")
+	}
+}
+
+// Policy decides how a conflict is resolved.
+type Policy func(Conflict) Resolution
+
+// LastWriterWins is a Policy that always takes the second (later) patch.
+func LastWriterWins(Conflict) Resolution {
+	return Theirs
+}
+
+// FirstWriterWins is a Policy that always takes the first (earlier) patch.
+func FirstWriterWins(Conflict) Resolution {
+	return Ours
+}
+
+// PreferNull is a Policy that takes whichever patch sets the field to null.
+// When neither does it falls back to LastWriterWins.
+func PreferNull(c Conflict) Resolution {
+	if c.Ours.Null {
+		return Ours
+	}
+	return Theirs
+}
+
+// Change is the value of a single field in a model or patch. Null is true
+// when there is no value (an unset model field is also reported as null).
+type Change struct {
+	Null  bool
+	Value any
+}
+
+// Conflict is a field that was changed by both patches in different ways.
+type Conflict struct {
+	// Field is the name of the field, see the package documentation for how
+	// names are derived.
+	Field string
+
+	Base   Change
+	Ours   Change
+	Theirs Change
+
+	// Resolution is the choice the policy made.
+	Resolution Resolution
+}
+
+// MergeVal merges two concurrent changes to the value of field. If the
+// changes do not conflict the result is the same as ours.Or(theirs).
+// Otherwise the policy is consulted and the conflict is returned with its
+// Resolution. The base value is not known so the conflict's Base is empty.
+func MergeVal[T comparable](field string, ours, theirs omitnull.Val[T], policy Policy) (omitnull.Val[T], *Conflict) {
+	if ours.IsUnset() || theirs.IsUnset() || omitnull.Equal(ours, theirs) {
+		return ours.Or(theirs), nil
+	}
+
+	c := &Conflict{Field: field, Ours: valChange(ours), Theirs: valChange(theirs)}
+	c.Resolution = resolve(policy, *c)
+	switch c.Resolution {
+	case Ours:
+		return ours, c
+	case Theirs:
+		return theirs, c
+	default:
+		return omitnull.Val[T]{}, c
+	}
+}
+
+func valChange[T any](v omitnull.Val[T]) Change {
+	val, ok := v.Get()
+	if !ok {
+		return Change{Null: true}
+	}
+	return Change{Value: val}
+}
+
+func resolve(policy Policy, c Conflict) Resolution {
+	if policy == nil {
+		return Skip
+	}
+	return policy(c)
+}
+
+// Merge performs a three-way merge of two patches that were both created
+// against the model base.
+//
+// A change in either patch that sets a field to what it already is in base
+// is ignored. When only one patch changes a field, or both make the same
+// change, that change is kept using the Val's Or method. When both change a
+// field differently the conflict is resolved by the policy and recorded in
+// the returned slice in field order. A nil policy resolves all conflicts
+// with Skip.
+//
+// The zero value of a null.Val is null rather than unset, so when a conflict
+// on a null.Val field is skipped the field is set to the base value instead.
+// Applying the merged patch then leaves the field as it is. It is an error if
+// the base value cannot be stored in the field.
+//
+// Fields are matched between P and M the same way as Diff.
+func Merge[P any, M any](base M, ours, theirs P, policy Policy) (P, []Conflict, error) {
+	var result P
+
+	rv := reflect.ValueOf(&result).Elem()
+	if rv.Kind() != reflect.Struct {
+		return result, nil, errors.New("patch: patch type must be a struct")
+	}
+	bv := reflect.Indirect(reflect.ValueOf(base))
+	if bv.Kind() != reflect.Struct {
+		return result, nil, errors.New("patch: model must be a struct or pointer to a struct")
+	}
+	ov := reflect.ValueOf(ours)
+	tv := reflect.ValueOf(theirs)
+
+	var conflicts []Conflict
+	modelFields := optreflect.Fields(bv.Type(), TagName)
+	for _, pf := range optreflect.Fields(rv.Type(), TagName) {
+		if optreflect.KindOf(pf.Type) == optreflect.KindNone {
+			continue
+		}
+
+		mf, ok := optreflect.FieldByName(modelFields, pf.Name)
+		if !ok {
+			return result, nil, &FieldError{Field: pf.Name, Src: pf.Type, Err: ErrNoField}
+		}
+
+		base, _ := mf.Value(bv)
+		ours, _ := pf.Value(ov)
+		theirs, _ := pf.Value(tv)
+		baseState, baseVal := optreflect.ModelState(base)
+		ourField := effective(ours, baseState, baseVal)
+		theirField := effective(theirs, baseState, baseVal)
+
+		ourState, ourVal := optreflect.Get(ourField)
+		theirState, theirVal := optreflect.Get(theirField)
+
+		field := pf.Alloc(rv)
+		if ourState == optreflect.StateUnset || theirState == optreflect.StateUnset ||
+			(ourState == theirState && (ourState != optreflect.StateSet || equal(ourVal, theirVal))) {
+			field.Set(ourField.MethodByName("Or").Call([]reflect.Value{theirField})[0])
+			continue
+		}
+
+		c := Conflict{
+			Field:  pf.Name,
+			Base:   change(baseState, baseVal),
+			Ours:   change(ourState, ourVal),
+			Theirs: change(theirState, theirVal),
+		}
+		c.Resolution = resolve(policy, c)
+		conflicts = append(conflicts, c)
+
+		switch c.Resolution {
+		case Ours:
+			field.Set(ourField)
+		case Theirs:
+			field.Set(theirField)
+		default:
+			if optreflect.KindOf(pf.Type).CanUnset() {
+				break
+			}
+			if baseState == optreflect.StateSet && !baseVal.Type().AssignableTo(optreflect.ElemType(pf.Type)) {
+				return result, nil, &FieldError{Field: pf.Name, Src: pf.Type, Dst: mf.Type, Err: ErrTypeMismatch}
+			}
+			if !optreflect.SetState(field, baseState, baseVal) {
+				return result, nil, &FieldError{Field: pf.Name, Src: pf.Type, Dst: mf.Type, Err: ErrNotUnsettable}
+			}
+		}
+	}
+
+	return result, conflicts, nil
+}
+
+// effective returns an unset Val of the same type as v if v would not change
+// the base value, otherwise v. Values that cannot be unset are returned as-is.
+func effective(v reflect.Value, baseState optreflect.State, baseVal reflect.Value) reflect.Value {
+	if !optreflect.KindOf(v.Type()).CanUnset() {
+		return v
+	}
+
+	state, val := optreflect.Get(v)
+	if state == optreflect.StateUnset || state != baseState {
+		return v
+	}
+	if state == optreflect.StateSet && (val.Type() != baseVal.Type() || !equal(val, baseVal)) {
+		return v
+	}
+	return reflect.Zero(v.Type())
+}
+
+func change(state optreflect.State, val reflect.Value) Change {
+	if state != optreflect.StateSet {
+		return Change{Null: true}
+	}
+	return Change{Value: val.Interface()}
+}
@@ -0,0 +1,190 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+func TestMergeVal(t *testing.T) {
+	t.Parallel()
+
+	var unset omitnull.Val[int]
+	null := omitnull.FromPtr[int](nil)
+	five, six := omitnull.From(5), omitnull.From(6)
+
+	tests := []struct {
+		Name     string
+		Ours     omitnull.Val[int]
+		Theirs   omitnull.Val[int]
+		Policy   Policy
+		Want     omitnull.Val[int]
+		Conflict *Conflict
+	}{
+		{Name: "unset", Ours: unset, Theirs: unset, Want: unset},
+		{Name: "ours", Ours: five, Theirs: unset, Want: five},
+		{Name: "theirs", Ours: unset, Theirs: null, Want: null},
+		{Name: "same", Ours: five, Theirs: five, Want: five},
+		{Name: "lww", Ours: five, Theirs: six, Policy: LastWriterWins, Want: six, Conflict: &Conflict{Field: "f", Ours: Change{Value: 5}, Theirs: Change{Value: 6}, Resolution: Theirs}},
+		{Name: "fww", Ours: five, Theirs: six, Policy: FirstWriterWins, Want: five, Conflict: &Conflict{Field: "f", Ours: Change{Value: 5}, Theirs: Change{Value: 6}, Resolution: Ours}},
+		{Name: "prefernull", Ours: null, Theirs: six, Policy: PreferNull, Want: null, Conflict: &Conflict{Field: "f", Ours: Change{Null: true}, Theirs: Change{Value: 6}, Resolution: Ours}},
+		{Name: "prefernulltheirs", Ours: five, Theirs: null, Policy: PreferNull, Want: null, Conflict: &Conflict{Field: "f", Ours: Change{Value: 5}, Theirs: Change{Null: true}, Resolution: Theirs}},
+		{Name: "nopolicy", Ours: five, Theirs: six, Want: unset, Conflict: &Conflict{Field: "f", Ours: Change{Value: 5}, Theirs: Change{Value: 6}, Resolution: Skip}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			got, conflict := MergeVal("f", test.Ours, test.Theirs, test.Policy)
+			if !omitnull.Equal(got, test.Want) {
+				t.Errorf("want: %#v, got: %#v", test.Want, got)
+			}
+			if !reflect.DeepEqual(conflict, test.Conflict) {
+				t.Errorf("conflict wrong\nwant: %#v\ngot:  %#v", test.Conflict, conflict)
+			}
+		})
+	}
+}
+
+type mergeModel struct {
+	Name  string
+	Age   *int
+	Email string
+	Tags  []string
+}
+
+type mergePatch struct {
+	Name  omit.Val[string]
+	Age   omitnull.Val[int]
+	Email omitnull.Val[string]
+	Tags  omitnull.Val[[]string]
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	age := 30
+	base := mergeModel{Name: "a", Age: &age, Email: "a@example.com", Tags: []string{"x"}}
+
+	ours := mergePatch{
+		Name:  omit.From("b"),
+		Age:   omitnull.From(31),
+		Email: omitnull.From("a@example.com"), // no-op against base
+		Tags:  omitnull.From([]string{"y"}),
+	}
+	theirs := mergePatch{
+		Age:   omitnull.FromPtr[int](nil),
+		Email: omitnull.From("c@example.com"),
+		Tags:  omitnull.From([]string{"y"}),
+	}
+
+	result, conflicts, err := Merge(base, ours, theirs, PreferNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Name.MustGet() != "b" {
+		t.Error("name wrong")
+	}
+	if !result.Age.IsNull() {
+		t.Error("age should be null")
+	}
+	if result.Email.MustGet() != "c@example.com" {
+		t.Error("email wrong")
+	}
+	if tags := result.Tags.MustGet(); len(tags) != 1 || tags[0] != "y" {
+		t.Error("tags wrong")
+	}
+
+	want := []Conflict{{
+		Field:      "Age",
+		Base:       Change{Value: 30},
+		Ours:       Change{Value: 31},
+		Theirs:     Change{Null: true},
+		Resolution: Theirs,
+	}}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("conflicts wrong\nwant: %#v\ngot:  %#v", want, conflicts)
+	}
+
+	result, conflicts, err = Merge(&base, ours, theirs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Age.IsUnset() || len(conflicts) != 1 || conflicts[0].Resolution != Skip {
+		t.Error("expected the conflict to be skipped")
+	}
+}
+
+func TestMergeSkipNull(t *testing.T) {
+	t.Parallel()
+
+	type model struct {
+		Name  *string
+		Email string
+	}
+	type nullPatch struct {
+		Name  null.Val[string]
+		Email null.Val[string]
+	}
+
+	name := "a"
+	base := model{Name: &name, Email: "a@example.com"}
+	ours := nullPatch{Name: null.From("b"), Email: null.From("b@example.com")}
+	theirs := nullPatch{Name: null.From("c"), Email: null.From("a@example.com")}
+
+	result, conflicts, err := Merge(base, ours, theirs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatal("expected two conflicts:", conflicts)
+	}
+	if result.Name.MustGet() != "a" || result.Email.MustGet() != "a@example.com" {
+		t.Errorf("skipped fields should keep the base value: %#v", result)
+	}
+
+	base.Name = nil
+	result, _, err = Merge(base, ours, theirs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Name.IsNull() {
+		t.Error("skipped field should keep the null base value")
+	}
+
+	type omitModel struct{ Name omit.Val[string] }
+	if _, _, err := Merge(omitModel{}, ours, theirs, nil); !errors.Is(err, ErrNotUnsettable) {
+		t.Error("expected ErrNotUnsettable, got:", err)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	t.Parallel()
+
+	type other struct{ Other omit.Val[int] }
+	if _, _, err := Merge(mergeModel{}, other{}, other{}, nil); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+	if _, _, err := Merge(5, mergePatch{}, mergePatch{}, nil); err == nil {
+		t.Error("expected an error for a non-struct model")
+	}
+}
+
+func TestResolutionStringer(t *testing.T) {
+	t.Parallel()
+
+	if Skip.String() != "skip" || Ours.String() != "ours" || Theirs.String() != "theirs" {
+		t.Error("bad value")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	_ = Resolution(99).String()
+}
@@ -0,0 +1,201 @@
+// Package patch works with structs made up of omit, null and omitnull
+// values that describe partial updates to another struct.
+//
+// A patch struct typically looks like the following, where each field that
+// is unset is left alone, each field that is null is cleared and each field
+// that is set is written:
+//
+//	type UserPatch struct {
+//		Name omit.Val[string]
+//		Age  omitnull.Val[int] `patch:"Years"`
+//	}
+//
+// Fields are matched between structs by their Go name, or by the name in the
+// `patch` struct tag when present. A tag of "-" excludes a field entirely.
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// TagName is the struct tag used to rename fields for matching.
+const TagName = "patch"
+
+var (
+	// ErrNoField is returned when a field in one struct has no counterpart
+	// in the other.
+	ErrNoField = errors.New("no matching field")
+	// ErrTypeMismatch is returned when a value cannot be assigned to the
+	// field it was matched with.
+	ErrTypeMismatch = errors.New("types are not assignable")
+	// ErrNotNullable is returned when a null must be stored in a field that
+	// has no way to represent it.
+	ErrNotNullable = errors.New("field cannot hold null")
+	// ErrNotUnsettable is returned when a merged field must be left unset
+	// but its Val type has no unset state.
+	ErrNotUnsettable = errors.New("field cannot be unset")
+)
+
+// FieldError describes why a field could not be processed.
+type FieldError struct {
+	// Field is the name of the field, see the package documentation for
+	// how names are derived.
+	Field string
+	// Src is the type of the field in the patch, Dst is the type of the
+	// field it was matched with. Dst is nil when there was no match.
+	Src reflect.Type
+	Dst reflect.Type
+	// Err is one of the sentinel errors in this package.
+	Err error
+}
+
+// Error implements error
+func (f *FieldError) Error() string {
+	if f.Dst == nil {
+		return fmt.Sprintf("patch: field %s (%s): %v", f.Field, f.Src, f.Err)
+	}
+	return fmt.Sprintf("patch: field %s (%s -> %s): %v", f.Field, f.Src, f.Dst, f.Err)
+}
+
+// Unwrap returns the underlying sentinel error
+func (f *FieldError) Unwrap() error {
+	return f.Err
+}
+
+// Apply copies the set and null fields of the patch struct src onto the
+// struct pointed to by dst. Fields in src that are not one of the Val types
+// are ignored, as are unset fields.
+//
+// Set values are written to dst fields of type T, *T or any of the Val types
+// wrapping T. Nulls are written to null.Val and omitnull.Val fields and as
+// nil to pointer, interface, map and slice fields, any other destination is
+// an ErrNotNullable error.
+//
+// All fields are checked before any are written so on error dst is left
+// untouched. Errors pertaining to a specific field are of type *FieldError.
+func Apply(dst, src any) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() || dv.Elem().Kind() != reflect.Struct {
+		return errors.New("patch: destination must be a non-nil pointer to a struct")
+	}
+	dv = dv.Elem()
+
+	sv := reflect.Indirect(reflect.ValueOf(src))
+	if sv.Kind() != reflect.Struct {
+		return errors.New("patch: source must be a struct or pointer to a struct")
+	}
+
+	type assignment struct {
+		dst   optreflect.Field
+		state optreflect.State
+		val   reflect.Value
+	}
+
+	dstFields := optreflect.Fields(dv.Type(), TagName)
+	var assignments []assignment
+	for _, sf := range optreflect.Fields(sv.Type(), TagName) {
+		if optreflect.KindOf(sf.Type) == optreflect.KindNone {
+			continue
+		}
+
+		df, ok := optreflect.FieldByName(dstFields, sf.Name)
+		if !ok {
+			return &FieldError{Field: sf.Name, Src: sf.Type, Err: ErrNoField}
+		}
+
+		field, ok := sf.Value(sv)
+		if !ok {
+			continue
+		}
+		state, val := optreflect.Get(field)
+		if state == optreflect.StateUnset {
+			continue
+		}
+
+		if err := checkAssign(df.Type, state, optreflect.ElemType(sf.Type)); err != nil {
+			return &FieldError{Field: sf.Name, Src: sf.Type, Dst: df.Type, Err: err}
+		}
+
+		assignments = append(assignments, assignment{
+			dst:   df,
+			state: state,
+			val:   val,
+		})
+	}
+
+	for _, a := range assignments {
+		assign(a.dst.Alloc(dv), a.state, a.val)
+	}
+
+	return nil
+}
+
+// checkAssign ensures that assign will not panic when storing a value of
+// type src with the given state into dst.
+func checkAssign(dst reflect.Type, state optreflect.State, src reflect.Type) error {
+	if state == optreflect.StateNull {
+		if kind := optreflect.KindOf(dst); kind != optreflect.KindNone {
+			if !kind.CanNull() {
+				return ErrNotNullable
+			}
+			return nil
+		}
+		if !nilable(dst) {
+			return ErrNotNullable
+		}
+		return nil
+	}
+
+	switch {
+	case src.AssignableTo(dst):
+	case optreflect.KindOf(dst) != optreflect.KindNone:
+		if !src.AssignableTo(optreflect.ElemType(dst)) {
+			return ErrTypeMismatch
+		}
+	case dst.Kind() == reflect.Pointer:
+		if !src.AssignableTo(dst.Elem()) {
+			return ErrTypeMismatch
+		}
+	default:
+		return ErrTypeMismatch
+	}
+
+	return nil
+}
+
+// assign stores val (or null) into dst, checkAssign must have approved this
+// operation first.
+func assign(dst reflect.Value, state optreflect.State, val reflect.Value) {
+	if state == optreflect.StateNull {
+		if optreflect.KindOf(dst.Type()) != optreflect.KindNone {
+			optreflect.SetNull(dst)
+		} else {
+			dst.SetZero()
+		}
+		return
+	}
+
+	switch {
+	case val.Type().AssignableTo(dst.Type()):
+		dst.Set(val)
+	case optreflect.KindOf(dst.Type()) != optreflect.KindNone:
+		optreflect.Set(dst, val)
+	default:
+		ptr := reflect.New(dst.Type().Elem())
+		ptr.Elem().Set(val)
+		dst.Set(ptr)
+	}
+}
+
+func nilable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return true
+	default:
+		return false
+	}
+}
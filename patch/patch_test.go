@@ -0,0 +1,247 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type user struct {
+	Name     string
+	Age      null.Val[int]
+	Nickname *string
+	Email    omit.Val[string]
+	Score    omitnull.Val[float64]
+	Years    int `patch:"years"`
+	Tags     []string
+}
+
+type userPatch struct {
+	ID       int
+	Name     omit.Val[string]
+	Age      omitnull.Val[int]
+	Nickname omitnull.Val[string]
+	Email    omit.Val[string]
+	Score    null.Val[float64]
+	Age2     omit.Val[int] `patch:"years"`
+	Tags     omitnull.Val[[]string]
+	Ignored  omitnull.Val[int] `patch:"-"`
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	nick := "bob"
+	u := user{
+		Name:     "robert",
+		Age:      null.From(30),
+		Nickname: &nick,
+		Score:    omitnull.From(1.5),
+		Years:    3,
+		Tags:     []string{"a"},
+	}
+
+	p := userPatch{
+		ID:       5,
+		Name:     omit.From("rob"),
+		Nickname: omitnull.From("robby"),
+		Email:    omit.From("rob@example.com"),
+		Score:    null.Val[float64]{},
+		Age2:     omit.From(4),
+		Tags:     omitnull.FromPtr[[]string](nil),
+	}
+
+	if err := Apply(&u, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Name != "rob" {
+		t.Error("name wrong:", u.Name)
+	}
+	if u.Age.MustGet() != 30 {
+		t.Error("unset age should have been left alone")
+	}
+	if u.Nickname == nil || *u.Nickname != "robby" {
+		t.Error("nickname wrong")
+	}
+	if nick != "bob" {
+		t.Error("should not have written through the old pointer")
+	}
+	if u.Email.MustGet() != "rob@example.com" {
+		t.Error("email wrong")
+	}
+	if !u.Score.IsNull() {
+		t.Error("score should be null")
+	}
+	if u.Years != 4 {
+		t.Error("years wrong:", u.Years)
+	}
+	if u.Tags != nil {
+		t.Error("tags should be nil")
+	}
+
+	p = userPatch{Age: omitnull.FromPtr[int](nil), Nickname: omitnull.FromPtr[string](nil)}
+	if err := Apply(&u, &p); err != nil {
+		t.Fatal(err)
+	}
+	if !u.Age.IsNull() {
+		t.Error("age should be null")
+	}
+	if u.Nickname != nil {
+		t.Error("nickname should be nil")
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name  string
+		Src   any
+		Field string
+		Err   error
+	}{
+		{
+			Name: "mismatch",
+			Src: struct {
+				Name omit.Val[int]
+			}{Name: omit.From(5)},
+			Field: "Name",
+			Err:   ErrTypeMismatch,
+		},
+		{
+			Name: "nullplain",
+			Src: struct {
+				Name omitnull.Val[string]
+			}{Name: omitnull.FromPtr[string](nil)},
+			Field: "Name",
+			Err:   ErrNotNullable,
+		},
+		{
+			Name: "nullomit",
+			Src: struct {
+				Email null.Val[string]
+			}{},
+			Field: "Email",
+			Err:   ErrNotNullable,
+		},
+		{
+			Name: "nofield",
+			Src: struct {
+				Missing omit.Val[string]
+			}{},
+			Field: "Missing",
+			Err:   ErrNoField,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			u := user{Name: "robert"}
+			err := Apply(&u, test.Src)
+
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("expected a field error, got: %v", err)
+			}
+			if fieldErr.Field != test.Field {
+				t.Error("field name wrong:", fieldErr.Field)
+			}
+			if !errors.Is(err, test.Err) {
+				t.Errorf("expected %v, got: %v", test.Err, err)
+			}
+			if u.Name != "robert" {
+				t.Error("should not have modified the destination")
+			}
+		})
+	}
+
+	if err := Apply(user{}, userPatch{}); err == nil {
+		t.Error("expected an error for a non-pointer destination")
+	}
+	if err := Apply(&user{}, 5); err == nil {
+		t.Error("expected an error for a non-struct source")
+	}
+}
+
+func TestApplyAtomic(t *testing.T) {
+	t.Parallel()
+
+	u := user{Name: "robert"}
+	p := struct {
+		Name omit.Val[string]
+		Age  omit.Val[string]
+	}{Name: omit.From("rob"), Age: omit.From("old")}
+
+	if err := Apply(&u, p); err == nil {
+		t.Fatal("expected an error")
+	}
+	if u.Name != "robert" {
+		t.Error("name should not have been written")
+	}
+}
+
+func TestApplyEmbedded(t *testing.T) {
+	t.Parallel()
+
+	type base struct {
+		ID omit.Val[int]
+	}
+	type embedPatch struct {
+		base
+		Name omit.Val[string]
+	}
+	type model struct {
+		ID   int
+		Name string
+	}
+
+	var m model
+	if err := Apply(&m, embedPatch{base: base{ID: omit.From(3)}, Name: omit.From("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 3 || m.Name != "x" {
+		t.Errorf("wrong values: %#v", m)
+	}
+}
+
+func TestApplyEmbeddedDominance(t *testing.T) {
+	t.Parallel()
+
+	type Base struct {
+		ID   omit.Val[int]
+		Name omit.Val[string]
+	}
+	type Other struct {
+		ID omit.Val[int]
+	}
+	type embedPatch struct {
+		*Base
+		Other
+		Name omit.Val[string]
+	}
+	type model struct {
+		ID   int
+		Name string
+	}
+
+	m := model{ID: 1}
+	p := embedPatch{Base: &Base{ID: omit.From(2), Name: omit.From("shadowed")}, Other: Other{ID: omit.From(3)}, Name: omit.From("x")}
+	if err := Apply(&m, p); err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 1 || m.Name != "x" {
+		t.Errorf("ambiguous and shadowed fields should be dropped: %#v", m)
+	}
+
+	m = model{ID: 1}
+	if err := Apply(&m, embedPatch{Name: omit.From("y")}); err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 1 || m.Name != "y" {
+		t.Errorf("wrong values: %#v", m)
+	}
+}
@@ -0,0 +1,294 @@
+package opt
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aarondl/opt/internal/globaldata"
+)
+
+// ErrPGArray is returned when text is not a valid one-dimensional Postgres
+// array literal.
+var ErrPGArray = errors.New("invalid postgres array")
+
+// ParsePGArray parses the text format of a one-dimensional Postgres array
+// such as {1,"a b",NULL}. NULL elements are returned as nil.
+//
+// Elements may be quoted, inside quotes and in unquoted elements a backslash
+// escapes the next character. Whitespace around unquoted elements is ignored
+// and an optional dimension decoration like [1:3]= is skipped.
+func ParsePGArray(text string) ([]*string, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "[") {
+		_, rest, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed dimensions", ErrPGArray)
+		}
+		text = strings.TrimSpace(rest)
+	}
+
+	if !strings.HasPrefix(text, "{") || !strings.HasSuffix(text, "}") {
+		return nil, fmt.Errorf("%w: must be surrounded by braces", ErrPGArray)
+	}
+	text = text[1 : len(text)-1]
+
+	elems := []*string{}
+	if strings.TrimSpace(text) == "" {
+		return elems, nil
+	}
+
+	for i := 0; ; {
+		elem, n, err := parsePGArrayElem(text[i:])
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+		i += n
+
+		if i == len(text) {
+			return elems, nil
+		}
+		if text[i] != ',' {
+			return nil, fmt.Errorf("%w: unexpected %q after element %d", ErrPGArray, text[i], len(elems))
+		}
+		i++
+	}
+}
+
+// parsePGArrayElem parses the element at the start of text, returning it and
+// the number of bytes consumed which stops short of the next delimiter.
+func parsePGArrayElem(text string) (*string, int, error) {
+	i := 0
+	for i < len(text) && isPGSpace(text[i]) {
+		i++
+	}
+
+	var sb strings.Builder
+	if i < len(text) && text[i] == '"' {
+		for i++; ; i++ {
+			if i == len(text) {
+				return nil, 0, fmt.Errorf("%w: unterminated quoted element", ErrPGArray)
+			}
+			switch c := text[i]; c {
+			case '"':
+				i++
+				for i < len(text) && isPGSpace(text[i]) {
+					i++
+				}
+				s := sb.String()
+				return &s, i, nil
+			case '\\':
+				i++
+				if i == len(text) {
+					return nil, 0, fmt.Errorf("%w: unterminated quoted element", ErrPGArray)
+				}
+				sb.WriteByte(text[i])
+			default:
+				sb.WriteByte(c)
+			}
+		}
+	}
+
+	escaped := false
+	// trailing whitespace is dropped unless it was escaped
+	keep := 0
+	for ; i < len(text) && text[i] != ','; i++ {
+		switch c := text[i]; c {
+		case '{':
+			return nil, 0, fmt.Errorf("%w: multi-dimensional arrays are not supported", ErrPGArray)
+		case '}', '"':
+			return nil, 0, fmt.Errorf("%w: unexpected %q in element", ErrPGArray, c)
+		case '\\':
+			i++
+			if i == len(text) {
+				return nil, 0, fmt.Errorf("%w: trailing escape", ErrPGArray)
+			}
+			sb.WriteByte(text[i])
+			escaped = true
+			keep = sb.Len()
+		default:
+			sb.WriteByte(c)
+			if !isPGSpace(c) {
+				keep = sb.Len()
+			}
+		}
+	}
+
+	s := sb.String()[:keep]
+	if len(s) == 0 && !escaped {
+		return nil, 0, fmt.Errorf("%w: empty element", ErrPGArray)
+	}
+	if !escaped && strings.EqualFold(s, "NULL") {
+		return nil, i, nil
+	}
+	return &s, i, nil
+}
+
+// AppendPGArray appends the text format of a one-dimensional Postgres array
+// of elems to b, nil elements are written as NULL. It is the inverse of
+// ParsePGArray.
+func AppendPGArray(b []byte, elems []*string) []byte {
+	b = append(b, '{')
+	for i, elem := range elems {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if elem == nil {
+			b = append(b, "NULL"...)
+			continue
+		}
+		if !pgArrayNeedsQuotes(*elem) {
+			b = append(b, *elem...)
+			continue
+		}
+
+		b = append(b, '"')
+		for j := 0; j < len(*elem); j++ {
+			if c := (*elem)[j]; c == '"' || c == '\\' {
+				b = append(b, '\\')
+			}
+			b = append(b, (*elem)[j])
+		}
+		b = append(b, '"')
+	}
+	return append(b, '}')
+}
+
+func pgArrayNeedsQuotes(s string) bool {
+	if len(s) == 0 || strings.EqualFold(s, "NULL") {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '{', '}', ',', '"', '\\':
+			return true
+		default:
+			if isPGSpace(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isPGSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\v', '\f':
+		return true
+	}
+	return false
+}
+
+// scanPGArray is used by ConvertAssign to store an array literal in the slice
+// dv. Each element is converted with ConvertAssign, so NULL elements need
+// a destination that can hold NULL like a pointer or null.Val. Elements in the
+// hex format of bytea are decoded when dv is a slice of byte slices.
+func scanPGArray(dv reflect.Value, text string) error {
+	elems, err := ParsePGArray(text)
+	if err != nil {
+		return err
+	}
+
+	et := dv.Type().Elem()
+	bytea := et.Kind() == reflect.Slice && et.Elem().Kind() == reflect.Uint8
+
+	slice := reflect.MakeSlice(dv.Type(), len(elems), len(elems))
+	for i, elem := range elems {
+		var src any
+		if elem != nil {
+			src = *elem
+		}
+		if bytea && elem != nil && strings.HasPrefix(*elem, `\x`) {
+			b, err := hex.DecodeString((*elem)[2:])
+			if err != nil {
+				return fmt.Errorf("converting array element %d: %w", i, err)
+			}
+			src = b
+		}
+		if err := ConvertAssign(slice.Index(i).Addr().Interface(), src); err != nil {
+			return fmt.Errorf("converting array element %d: %w", i, err)
+		}
+	}
+	dv.Set(slice)
+	return nil
+}
+
+// ToDriverValueArray is ToDriverValue except that slices other than []byte
+// are rendered as Postgres array literals, it is used by the Value methods of
+// the Val types.
+func ToDriverValueArray(val any) (driver.Value, error) {
+	if _, ok := val.(driver.Valuer); ok {
+		return ToDriverValue(val)
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 ||
+		rv.Type().Implements(globaldata.EncodingTextMarshalerIntf) ||
+		rv.Type().Implements(globaldata.EncodingBinaryMarshalerIntf) {
+		return ToDriverValue(val)
+	}
+	if rv.IsNil() {
+		return nil, nil
+	}
+	return pgArrayValue(rv)
+}
+
+// pgArrayValue renders the slice rv as an array literal. Each element is
+// converted with ToDriverValue first, byte slices are written in the hex
+// format of bytea.
+func pgArrayValue(rv reflect.Value) (string, error) {
+	if et := rv.Type().Elem(); et.Kind() == reflect.Array ||
+		(et.Kind() == reflect.Slice && et.Elem().Kind() != reflect.Uint8) {
+		return "", fmt.Errorf("%w: multi-dimensional arrays are not supported", ErrPGArray)
+	}
+
+	elems := make([]*string, rv.Len())
+	for i := range elems {
+		val, err := ToDriverValue(rv.Index(i).Interface())
+		if err != nil {
+			return "", fmt.Errorf("converting array element %d: %w", i, err)
+		}
+
+		var s string
+		switch val := val.(type) {
+		case nil:
+			continue
+		case time.Time:
+			s = val.Format(time.RFC3339Nano)
+		case []byte:
+			if val == nil {
+				continue
+			}
+			s = `\x` + hex.EncodeToString(val)
+		case string:
+			s = val
+		default:
+			s = asString(val)
+		}
+		elems[i] = &s
+	}
+
+	return string(AppendPGArray(nil, elems)), nil
+}
+
+// asPGArrayText returns the text of src if it looks like an array literal.
+func asPGArrayText(src any) (string, bool) {
+	var text string
+	switch src := src.(type) {
+	case string:
+		text = src
+	case []byte:
+		text = string(src)
+	default:
+		return "", false
+	}
+
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	return text, strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")
+}
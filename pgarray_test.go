@@ -0,0 +1,205 @@
+package opt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func pgElems(elems ...any) []*string {
+	out := make([]*string, len(elems))
+	for i, e := range elems {
+		if s, ok := e.(string); ok {
+			out[i] = &s
+		}
+	}
+	return out
+}
+
+func TestParsePGArray(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want []*string
+	}{
+		{`{}`, pgElems()},
+		{` { } `, pgElems()},
+		{`{1,2,3}`, pgElems("1", "2", "3")},
+		{`{a,NULL,null,"NULL"}`, pgElems("a", nil, nil, "NULL")},
+		{`{"a b","",  c d  ,"x\"y\\z"}`, pgElems("a b", "", "c d", `x"y\z`)},
+		{`{a\,b,\NULL,c\ }`, pgElems("a,b", "NULL", "c ")},
+		{`{"{}",","}`, pgElems("{}", ",")},
+		{`[0:1]={1,2}`, pgElems("1", "2")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := ParsePGArray(test.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want: %v got: %v", derefAll(test.want), derefAll(got))
+			}
+		})
+	}
+}
+
+func TestParsePGArrayErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		``,
+		`1,2`,
+		`{1,2`,
+		`{1,,2}`,
+		`{1,}`,
+		`{{1,2},{3,4}}`,
+		`{"a}`,
+		`{"a"b}`,
+		`{a"b"}`,
+		`{a\}`,
+		`[1:2]{1,2}`,
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			if _, err := ParsePGArray(test); !errors.Is(err, ErrPGArray) {
+				t.Error("expected ErrPGArray, got:", err)
+			}
+		})
+	}
+}
+
+func TestAppendPGArray(t *testing.T) {
+	t.Parallel()
+
+	elems := pgElems("1", nil, "NULL", "", "a b", `x"y\z`, "{,}", "plain")
+	got := string(AppendPGArray([]byte("x="), elems))
+	want := `x={1,NULL,"NULL","","a b","x\"y\\z","{,}",plain}`
+	if got != want {
+		t.Errorf("want: %s got: %s", want, got)
+	}
+
+	back, err := ParsePGArray(got[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(elems, back) {
+		t.Errorf("want: %v got: %v", derefAll(elems), derefAll(back))
+	}
+
+	if got := string(AppendPGArray(nil, nil)); got != "{}" {
+		t.Error("empty array wrong:", got)
+	}
+}
+
+func TestConvertAssignPGArray(t *testing.T) {
+	t.Parallel()
+
+	var ints []int64
+	if err := ConvertAssign(&ints, []byte("{1,-2,3}")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ints, []int64{1, -2, 3}) {
+		t.Error("ints wrong:", ints)
+	}
+
+	var strs []string
+	if err := ConvertAssign(&strs, `{"a,b",c}`); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(strs, []string{"a,b", "c"}) {
+		t.Error("strs wrong:", strs)
+	}
+
+	var ptrs []*float64
+	if err := ConvertAssign(&ptrs, "{1.5,NULL}"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ptrs) != 2 || *ptrs[0] != 1.5 || ptrs[1] != nil {
+		t.Error("ptrs wrong:", ptrs)
+	}
+
+	var bools []bool
+	if err := ConvertAssign(&bools, "{t,f,true}"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bools, []bool{true, false, true}) {
+		t.Error("bools wrong:", bools)
+	}
+
+	if err := ConvertAssign(&ints, "{1,NULL}"); err == nil {
+		t.Error("expected an error for NULL into int64")
+	}
+	if err := ConvertAssign(&ints, "{1,x}"); err == nil {
+		t.Error("expected an error for a bad element")
+	}
+	if err := ConvertAssign(&ints, "{1"); !errors.Is(err, ErrPGArray) {
+		t.Error("expected ErrPGArray, got:", err)
+	}
+
+	var bytea [][]byte
+	if err := ConvertAssign(&bytea, `{"\\x00ff",NULL}`); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bytea, [][]byte{{0, 0xff}, nil}) {
+		t.Error("bytea wrong:", bytea)
+	}
+	if err := ConvertAssign(&bytea, `{"\\xzz"}`); err == nil {
+		t.Error("expected an error for bad hex")
+	}
+}
+
+func TestToDriverValueArray(t *testing.T) {
+	t.Parallel()
+
+	one := 1.5
+	tests := []struct {
+		in   any
+		want any
+	}{
+		{[]int64{1, -2}, "{1,-2}"},
+		{[]int{}, "{}"},
+		{[]int(nil), nil},
+		{[]string{"a b", "", "NULL", `"`}, `{"a b","","NULL","\""}`},
+		{[]*float64{&one, nil}, "{1.5,NULL}"},
+		{[]bool{true, false}, "{true,false}"},
+		{[]time.Time{time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)}, "{2000-01-02T03:04:05Z}"},
+		{userDefinedSlice{3}, "{3}"},
+		{[][]byte{{0, 0xff}, {}, nil}, `{"\\x00ff","\\x",NULL}`},
+		{[]byte("x"), []byte("x")},
+		{"x", "x"},
+	}
+
+	for _, test := range tests {
+		got, err := ToDriverValueArray(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%#v want: %v got: %v", test.in, test.want, got)
+		}
+	}
+
+	if _, err := ToDriverValueArray([][]int{{1}}); !errors.Is(err, ErrPGArray) {
+		t.Error("expected ErrPGArray for a nested slice, got:", err)
+	}
+
+	// plain ToDriverValue leaves slices to the driver
+	if got, err := ToDriverValue([]int64{1}); err != nil || !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("slice should be untouched: %v %v", got, err)
+	}
+}
+
+func derefAll(elems []*string) []any {
+	out := make([]any, len(elems))
+	for i, e := range elems {
+		if e != nil {
+			out[i] = *e
+		}
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+// Package presence reports which fields of a struct containing omit, null
+// and omitnull values were provided, typically after decoding a request body
+// with json.Unmarshal.
+package presence
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/aarondl/opt/internal/optreflect"
+	"github.com/aarondl/opt/jsonpatch"
+)
+
+// Field is a field that was provided (not unset).
+type Field struct {
+	// Path is the json name of the field and each of its parents, outermost
+	// first.
+	Path []string
+	// Null is true if the field was provided as null rather than a value.
+	Null bool
+}
+
+// String returns the dotted form of the path, ie. address.city
+func (f Field) String() string {
+	return strings.Join(f.Path, ".")
+}
+
+// Pointer returns the path as an RFC 6901 JSON Pointer, ie. /address/city
+func (f Field) Pointer() string {
+	sb := &strings.Builder{}
+	for _, p := range f.Path {
+		sb.WriteByte('/')
+		sb.WriteString(jsonpatch.EscapePath(p))
+	}
+	return sb.String()
+}
+
+// Fields walks the struct v and returns each Val field whose state is not
+// unset, in field order. Names come from json tags and fields tagged "-" are
+// skipped.
+//
+// When a set Val holds a struct its fields are reported after it with the
+// parent's name prefixed to their path. Plain struct fields (and non-nil
+// pointers to structs) are descended into as well but are not reported
+// themselves as their presence cannot be known. A null.Val that is null is
+// not reported either, json.Unmarshal leaves a missing one null so it cannot
+// be told apart from an explicit null. Pointers that lead back to a struct
+// that is already being walked are not followed.
+func Fields(v any) []Field {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	w := &walker{seen: map[seenPtr]bool{}}
+	w.descend(reflect.ValueOf(v), nil)
+	return w.fields
+}
+
+// Paths is like Fields but returns only the dotted path of each field.
+func Paths(v any) []string {
+	fields := Fields(v)
+	paths := make([]string, len(fields))
+	for i, f := range fields {
+		paths[i] = f.String()
+	}
+	return paths
+}
+
+// seenPtr identifies a pointer that is being walked
+type seenPtr struct {
+	t reflect.Type
+	p uintptr
+}
+
+type walker struct {
+	fields []Field
+	seen   map[seenPtr]bool
+}
+
+func (w *walker) walk(rv reflect.Value, parent []string) {
+	for _, f := range optreflect.Fields(rv.Type(), "json") {
+		path := append(parent[:len(parent):len(parent)], f.Name)
+		field, ok := f.Value(rv)
+		if !ok {
+			continue
+		}
+
+		kind := optreflect.KindOf(f.Type)
+		if kind == optreflect.KindNone {
+			w.descend(field, path)
+			continue
+		}
+
+		state, value := optreflect.Get(field)
+		switch {
+		case state == optreflect.StateUnset:
+			continue
+		case kind == optreflect.KindNull && state == optreflect.StateNull:
+			continue
+		case state == optreflect.StateNull:
+			w.fields = append(w.fields, Field{Path: path, Null: true})
+		default:
+			w.fields = append(w.fields, Field{Path: path})
+			w.descend(value, path)
+		}
+	}
+}
+
+// descend walks v if it is a struct or a pointer to one.
+func (w *walker) descend(v reflect.Value, path []string) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		key := seenPtr{t: v.Type(), p: v.Pointer()}
+		if w.seen[key] {
+			return
+		}
+		w.seen[key] = true
+		defer delete(w.seen, key)
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		w.walk(v, path)
+	}
+}
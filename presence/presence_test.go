@@ -0,0 +1,114 @@
+package presence
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type address struct {
+	City omitnull.Val[string] `json:"city"`
+	Zip  omitnull.Val[string] `json:"zip"`
+}
+
+type meta struct {
+	Source omit.Val[string] `json:"source"`
+}
+
+type userPatch struct {
+	Name     omit.Val[string]        `json:"name"`
+	Age      omitnull.Val[int]       `json:"age"`
+	Email    null.Val[string]        `json:"email"`
+	Created  omitnull.Val[time.Time] `json:"created"`
+	Address  omitnull.Val[address]   `json:"address"`
+	Meta     meta                    `json:"meta"`
+	MetaPtr  *meta                   `json:"meta_ptr"`
+	Secret   omit.Val[string]        `json:"-"`
+	Untagged omit.Val[int]
+}
+
+func TestFields(t *testing.T) {
+	t.Parallel()
+
+	var p userPatch
+	err := json.Unmarshal([]byte(`{
+		"age": null,
+		"email": "bob@example.com",
+		"created": "2000-01-01T00:00:00Z",
+		"address": {"city": "Toronto", "zip": null},
+		"meta": {"source": "api"},
+		"Untagged": 5
+	}`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Secret.Set("shh")
+
+	want := []Field{
+		{Path: []string{"age"}, Null: true},
+		{Path: []string{"email"}},
+		{Path: []string{"created"}},
+		{Path: []string{"address"}},
+		{Path: []string{"address", "city"}},
+		{Path: []string{"address", "zip"}, Null: true},
+		{Path: []string{"meta", "source"}},
+		{Path: []string{"Untagged"}},
+	}
+	if got := Fields(&p); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields wrong\nwant: %#v\ngot:  %#v", want, got)
+	}
+
+	p.MetaPtr = &meta{Source: omit.From("x")}
+	wantPaths := []string{"age", "email", "created", "address", "address.city",
+		"address.zip", "meta.source", "meta_ptr.source", "Untagged"}
+	if got := Paths(p); !reflect.DeepEqual(got, wantPaths) {
+		t.Errorf("paths wrong\nwant: %#v\ngot:  %#v", wantPaths, got)
+	}
+
+	p.Email = null.FromPtr[string](nil)
+	if got := Paths(p); reflect.DeepEqual(got, wantPaths) || got[1] != "created" {
+		t.Error("a null null.Val should not be reported:", got)
+	}
+
+	if Fields(5) != nil {
+		t.Error("expected nothing for non-struct")
+	}
+}
+
+func TestFieldFormats(t *testing.T) {
+	t.Parallel()
+
+	f := Field{Path: []string{"a/b", "c~d"}}
+	if f.String() != "a/b.c~d" {
+		t.Error("string wrong:", f.String())
+	}
+	if f.Pointer() != "/a~1b/c~0d" {
+		t.Error("pointer wrong:", f.Pointer())
+	}
+}
+
+type node struct {
+	Name  omit.Val[string]    `json:"name"`
+	Next  *node               `json:"next"`
+	Owner null.Val[*node]     `json:"owner"`
+	Child omitnull.Val[*node] `json:"child"`
+}
+
+func TestFieldsCycle(t *testing.T) {
+	t.Parallel()
+
+	n := &node{Name: omit.From("a")}
+	n.Next = n
+	n.Owner = null.From(n)
+	n.Child = omitnull.From(&node{Name: omit.From("b"), Next: n})
+
+	want := []string{"name", "owner", "child", "child.name"}
+	if got := Paths(n); !reflect.DeepEqual(got, want) {
+		t.Errorf("paths wrong\nwant: %#v\ngot:  %#v", want, got)
+	}
+}
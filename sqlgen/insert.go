@@ -0,0 +1,136 @@
+package sqlgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statement is a complete SQL statement and its arguments.
+type Statement struct {
+	SQL  string
+	Args []any
+	// Rows are the indexes of the rows passed to InsertMany that this
+	// statement inserts, in the order they appear in the VALUES list.
+	Rows []int
+}
+
+// Insert creates an INSERT statement for a single row. Unset fields are left
+// out of the column list entirely so that the database's DEFAULT for the
+// column applies, null fields are bound as a nil parameter and set fields
+// are bound using the field's Value method.
+//
+//	INSERT INTO users (age, name) VALUES ($1, $2)
+//
+// When no fields are null or set the statement uses DEFAULT VALUES which is
+// not supported by MySQL.
+func Insert(table string, row any, opts Options) (string, []any, error) {
+	cols, err := columns(row, opts.tag())
+	if err != nil {
+		return "", nil, fmt.Errorf("sqlgen: %w", err)
+	}
+
+	sb := &strings.Builder{}
+	args := writeInsert(sb, table, [][]column{cols}, opts)
+	return sb.String(), args, nil
+}
+
+// InsertMany creates multi-row INSERT statements for rows. Because each row
+// may have a different set of unset fields, rows are grouped by the columns
+// they provide and one statement is created for each group. Groups are
+// ordered by the first row that belongs to them. Rows with no null or set
+// fields cannot share a DEFAULT VALUES statement, so each of them gets its
+// own.
+//
+// See Insert for how fields are treated.
+func InsertMany[T any](table string, rows []T, opts Options) ([]Statement, error) {
+	type group struct {
+		rows []int
+		cols [][]column
+	}
+
+	var groups []*group
+	lookup := make(map[string]*group)
+	for i, row := range rows {
+		cols, err := columns(row, opts.tag())
+		if err != nil {
+			return nil, fmt.Errorf("sqlgen: row %d: %w", i, err)
+		}
+
+		// DEFAULT VALUES inserts a single row so rows without any columns
+		// each get their own statement
+		key := signature(cols)
+		g, ok := lookup[key]
+		if !ok || len(cols) == 0 {
+			g = &group{}
+			lookup[key] = g
+			groups = append(groups, g)
+		}
+		g.rows = append(g.rows, i)
+		g.cols = append(g.cols, cols)
+	}
+
+	stmts := make([]Statement, len(groups))
+	for i, g := range groups {
+		sb := &strings.Builder{}
+		args := writeInsert(sb, table, g.cols, opts)
+		stmts[i] = Statement{SQL: sb.String(), Args: args, Rows: g.rows}
+	}
+
+	return stmts, nil
+}
+
+// signature uniquely identifies the set of columns in a row
+func signature(cols []column) string {
+	sb := &strings.Builder{}
+	for _, c := range cols {
+		sb.WriteString(c.name)
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// writeInsert writes an INSERT statement for rows which must all have the
+// same columns in the same order. AtName placeholders have the row number
+// appended when there is more than one row to keep them unique.
+func writeInsert(sb *strings.Builder, table string, rows [][]column, opts Options) []any {
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(table)
+
+	if len(rows[0]) == 0 {
+		sb.WriteString(" DEFAULT VALUES")
+		return nil
+	}
+
+	sb.WriteString(" (")
+	for i, c := range rows[0] {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(c.name)
+	}
+	sb.WriteString(") VALUES ")
+
+	var args []any
+	for r, cols := range rows {
+		if r != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for i, c := range cols {
+			if i != 0 {
+				sb.WriteString(", ")
+			}
+
+			name := c.name
+			if len(rows) > 1 {
+				name = fmt.Sprintf("%s_%d", c.name, r+1)
+			}
+
+			args = append(args, opts.arg(name, c.value))
+			opts.placeholder(sb, len(args), name)
+		}
+		sb.WriteByte(')')
+	}
+
+	return args
+}
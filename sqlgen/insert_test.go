@@ -0,0 +1,114 @@
+package sqlgen
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type userRow struct {
+	Name omit.Val[string]  `db:"name"`
+	Age  omitnull.Val[int] `db:"age"`
+}
+
+func TestInsert(t *testing.T) {
+	t.Parallel()
+
+	row := userRow{Age: omitnull.FromPtr[int](nil)}
+	query, args, err := Insert("users", row, Options{Placeholder: Dollar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "INSERT INTO users (age) VALUES ($1)" {
+		t.Error("sql wrong:", query)
+	}
+	if !reflect.DeepEqual(args, []any{nil}) {
+		t.Errorf("args wrong: %#v", args)
+	}
+
+	query, args, err = Insert("users", userRow{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "INSERT INTO users DEFAULT VALUES" {
+		t.Error("sql wrong:", query)
+	}
+	if len(args) != 0 {
+		t.Errorf("args wrong: %#v", args)
+	}
+
+	if _, _, err := Insert("users", "row", Options{}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestInsertMany(t *testing.T) {
+	t.Parallel()
+
+	rows := []userRow{
+		{Name: omit.From("a"), Age: omitnull.From(1)},
+		{Name: omit.From("b")},
+		{Name: omit.From("c"), Age: omitnull.FromPtr[int](nil)},
+	}
+
+	stmts, err := InsertMany("users", rows, Options{Placeholder: Dollar})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Statement{
+		{
+			SQL:  "INSERT INTO users (name, age) VALUES ($1, $2), ($3, $4)",
+			Args: []any{"a", int64(1), "c", nil},
+			Rows: []int{0, 2},
+		},
+		{
+			SQL:  "INSERT INTO users (name) VALUES ($1)",
+			Args: []any{"b"},
+			Rows: []int{1},
+		},
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Errorf("statements wrong\nwant: %#v\ngot:  %#v", want, stmts)
+	}
+
+	stmts, err = InsertMany("users", rows[:2], Options{Placeholder: AtName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmts[0].SQL != "INSERT INTO users (name, age) VALUES (@name, @age)" {
+		t.Error("sql wrong:", stmts[0].SQL)
+	}
+
+	stmts, err = InsertMany("users", []userRow{rows[0], rows[0]}, Options{Placeholder: AtName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmts[0].SQL != "INSERT INTO users (name, age) VALUES (@name_1, @age_1), (@name_2, @age_2)" {
+		t.Error("sql wrong:", stmts[0].SQL)
+	}
+	if stmts[0].Args[2] != sql.Named("name_2", "a") {
+		t.Errorf("args wrong: %#v", stmts[0].Args)
+	}
+
+	stmts, err = InsertMany("users", []userRow{{}, rows[1], {}, {}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Statement{
+		{SQL: "INSERT INTO users DEFAULT VALUES", Rows: []int{0}},
+		{SQL: "INSERT INTO users (name) VALUES (?)", Args: []any{"b"}, Rows: []int{1}},
+		{SQL: "INSERT INTO users DEFAULT VALUES", Rows: []int{2}},
+		{SQL: "INSERT INTO users DEFAULT VALUES", Rows: []int{3}},
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Errorf("statements wrong\nwant: %#v\ngot:  %#v", want, stmts)
+	}
+	_, err = InsertMany("users", []any{userRow{}, "row"}, Options{})
+	if err == nil || err.Error() != "sqlgen: row 1: value must be a struct or pointer to a struct" {
+		t.Error("error wrong:", err)
+	}
+}
@@ -0,0 +1,162 @@
+// Package sqlgen builds SQL statement fragments from structs of omit, null and
+// omitnull values so that only the fields that have been provided take part
+// in the statement.
+//
+// Column names come from the `db` struct tag (see Options.Tag) and fall back
+// to the Go field name. Fields that are not one of the Val types are ignored.
+package sqlgen
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aarondl/opt/internal/optreflect"
+)
+
+// TagName is the default struct tag that column names are read from.
+const TagName = "db"
+
+// ErrNoColumns is returned when there are no fields in the struct that are
+// not unset. For an UPDATE this means there is nothing to do and the caller
+// can skip the round trip to the database.
+var ErrNoColumns = errors.New("sqlgen: no columns to write")
+
+// Placeholder is a style of bind parameter
+type Placeholder int
+
+const (
+	// Question uses ? for each parameter (MySQL, SQLite)
+	Question Placeholder = iota
+	// Dollar uses $N for each parameter (Postgres)
+	Dollar
+	// AtName uses @column for each parameter and returns sql.NamedArg values
+	// in the argument slice (SQL Server)
+	AtName
+)
+
+// Options control the generated SQL
+type Options struct {
+	// Placeholder is the bind parameter style to use
+	Placeholder Placeholder
+	// Tag is the struct tag to read column names from, TagName if empty.
+	Tag string
+	// Offset is added to the numbers of Dollar placeholders which allows the
+	// generated SQL to be preceded by other parameters.
+	Offset int
+}
+
+func (o Options) tag() string {
+	if len(o.Tag) == 0 {
+		return TagName
+	}
+	return o.Tag
+}
+
+// placeholder writes the bind parameter for column into sb, n is the 1-based
+// index of the argument.
+func (o Options) placeholder(sb *strings.Builder, n int, column string) {
+	switch o.Placeholder {
+	case Dollar:
+		sb.WriteByte('$')
+		sb.WriteString(strconv.Itoa(n + o.Offset))
+	case AtName:
+		sb.WriteByte('@')
+		sb.WriteString(column)
+	default:
+		sb.WriteByte('?')
+	}
+}
+
+// arg wraps the value for AtName placeholders.
+func (o Options) arg(column string, value driver.Value) any {
+	if o.Placeholder == AtName {
+		return sql.Named(column, value)
+	}
+	return value
+}
+
+// column is a single non-unset field of a struct.
+type column struct {
+	name  string
+	null  bool
+	value driver.Value
+}
+
+// columns returns the non-unset columns of the struct v in field order. Its
+// errors are not prefixed, callers add the "sqlgen:" prefix along with any
+// context of their own.
+func columns(v any, tag string) ([]column, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New("value must be a struct or pointer to a struct")
+	}
+
+	var cols []column
+	for _, f := range optreflect.Fields(rv.Type(), tag) {
+		if optreflect.KindOf(f.Type) == optreflect.KindNone {
+			continue
+		}
+
+		field, ok := f.Value(rv)
+		if !ok {
+			continue
+		}
+		switch optreflect.StateOf(field) {
+		case optreflect.StateUnset:
+			continue
+		case optreflect.StateNull:
+			cols = append(cols, column{name: f.Name, null: true})
+		default:
+			value, err := field.Interface().(driver.Valuer).Value()
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", f.Name, err)
+			}
+			cols = append(cols, column{name: f.Name, value: value})
+		}
+	}
+
+	return cols, nil
+}
+
+// Set creates the SET clause of an UPDATE statement from the struct patch.
+// Unset fields are skipped, null fields are assigned a literal NULL and set
+// fields are bound as parameters using the field's Value method.
+//
+//	SET age = $1, name = NULL
+//
+// If no fields are null or set ErrNoColumns is returned.
+func Set(patch any, opts Options) (string, []any, error) {
+	cols, err := columns(patch, opts.tag())
+	if err != nil {
+		return "", nil, fmt.Errorf("sqlgen: %w", err)
+	}
+	if len(cols) == 0 {
+		return "", nil, ErrNoColumns
+	}
+
+	var args []any
+	sb := &strings.Builder{}
+	sb.WriteString("SET ")
+	for i, c := range cols {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(c.name)
+		sb.WriteString(" = ")
+
+		if c.null {
+			sb.WriteString("NULL")
+			continue
+		}
+
+		args = append(args, opts.arg(c.name, c.value))
+		opts.placeholder(sb, len(args), c.name)
+	}
+
+	return sb.String(), args, nil
+}
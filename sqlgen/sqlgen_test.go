@@ -0,0 +1,117 @@
+package sqlgen
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aarondl/opt/null"
+	"github.com/aarondl/opt/omit"
+	"github.com/aarondl/opt/omitnull"
+)
+
+type userPatch struct {
+	ID       int
+	Age      omitnull.Val[int]    `db:"age"`
+	Name     omitnull.Val[string] `db:"name"`
+	Email    omit.Val[string]     `db:"email"`
+	Nickname null.Val[string]     `db:"nickname"`
+	Skip     omit.Val[string]     `db:"-"`
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	patch := userPatch{
+		ID:       5,
+		Age:      omitnull.From(5),
+		Name:     omitnull.FromPtr[string](nil),
+		Nickname: null.From("bob"),
+		Skip:     omit.From("skip"),
+	}
+
+	tests := []struct {
+		Name string
+		Opts Options
+		SQL  string
+		Args []any
+	}{
+		{
+			Name: "question",
+			SQL:  "SET age = ?, name = NULL, nickname = ?",
+			Args: []any{int64(5), "bob"},
+		},
+		{
+			Name: "dollar",
+			Opts: Options{Placeholder: Dollar},
+			SQL:  "SET age = $1, name = NULL, nickname = $2",
+			Args: []any{int64(5), "bob"},
+		},
+		{
+			Name: "dollaroffset",
+			Opts: Options{Placeholder: Dollar, Offset: 2},
+			SQL:  "SET age = $3, name = NULL, nickname = $4",
+			Args: []any{int64(5), "bob"},
+		},
+		{
+			Name: "atname",
+			Opts: Options{Placeholder: AtName},
+			SQL:  "SET age = @age, name = NULL, nickname = @nickname",
+			Args: []any{sql.Named("age", int64(5)), sql.Named("nickname", "bob")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			query, args, err := Set(&patch, test.Opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != test.SQL {
+				t.Errorf("sql wrong\nwant: %s\ngot:  %s", test.SQL, query)
+			}
+			if !reflect.DeepEqual(args, test.Args) {
+				t.Errorf("args wrong\nwant: %#v\ngot:  %#v", test.Args, args)
+			}
+		})
+	}
+}
+
+func TestSetTag(t *testing.T) {
+	t.Parallel()
+
+	patch := struct {
+		Age omit.Val[int] `sql:"user_age"`
+	}{Age: omit.From(1)}
+
+	query, _, err := Set(patch, Options{Tag: "sql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "SET user_age = ?" {
+		t.Error("sql wrong:", query)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	t.Parallel()
+
+	patch := struct {
+		Age omit.Val[int] `db:"age"`
+	}{}
+	if _, _, err := Set(patch, Options{}); !errors.Is(err, ErrNoColumns) {
+		t.Error("expected no columns error, got:", err)
+	}
+
+	if _, _, err := Set(5, Options{}); err == nil {
+		t.Error("expected an error for non-structs")
+	}
+
+	bad := struct {
+		Val omit.Val[uint64] `db:"val"`
+	}{Val: omit.From(uint64(1 << 63))}
+	if _, _, err := Set(bad, Options{}); err == nil {
+		t.Error("expected an error from Value")
+	}
+}
@@ -0,0 +1,36 @@
+package opt
+
+// TextOptions configure the text encoding used by the MarshalTextWith and
+// UnmarshalTextWith methods of the Val types. Unlike MarshalText, which is
+// only meant to be read back by this package, this encoding is meant to be
+// shared with other systems: environment variables, CSV, TOML, query strings
+// and the like.
+//
+// Set values are written as their plain text, null values as the Null text
+// and unset values are not written at all (the methods return nil text and
+// callers should leave the key, column or variable out).
+//
+// A set value whose text is the same as Null, or starts with Escape, is
+// prefixed with Escape so that it can still be represented: with the
+// TextNull options the string "null" is written as `\null` and `\n` is
+// written as `\\n`. When decoding a single leading Escape is removed.
+type TextOptions struct {
+	// Null is the text that represents null, for example "null", `\N` or
+	// "NULL". It may be empty, in which case an empty string value must be
+	// escaped.
+	Null string
+	// Escape is prefixed to values that would otherwise be mistaken for
+	// Null. If it is empty such values cannot be encoded and return an
+	// error instead.
+	Escape string
+}
+
+// Common text options
+var (
+	// TextNull uses the word null, as found in many config formats and APIs.
+	TextNull = TextOptions{Null: "null", Escape: `\`}
+	// TextPostgres uses \N which is the default of Postgres' COPY.
+	TextPostgres = TextOptions{Null: `\N`, Escape: `\`}
+	// TextSQL uses NULL as it appears in SQL and MySQL's exports.
+	TextSQL = TextOptions{Null: "NULL", Escape: `\`}
+)
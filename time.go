@@ -0,0 +1,75 @@
+package opt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeLayouts are tried in order when ConvertAssign stores a string or
+// []byte in a time.Time, as SQLite drivers and some MySQL configurations
+// return timestamps as text. Layouts without a zone are parsed as UTC.
+//
+// The defaults cover RFC 3339, SQLite's CURRENT_TIMESTAMP and the variants
+// its date functions accept, and MySQL's DATETIME and DATE.
+var TimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	time.DateOnly,
+}
+
+// UnixTimeUnit is how ConvertAssign interprets integers stored in a
+// time.Time: the number of seconds (the default), milliseconds, microseconds
+// or nanoseconds since the Unix epoch. Set it to 0 to reject integers.
+var UnixTimeUnit = time.Second
+
+// convertTime stores src in dest if it is text in one of TimeLayouts or an
+// integer in UnixTimeUnit, it returns false if src is neither.
+func convertTime(dest *time.Time, src any) (bool, error) {
+	switch src := src.(type) {
+	case string:
+		return true, parseTime(dest, src)
+	case []byte:
+		return true, parseTime(dest, string(src))
+	case int64:
+		return true, unixTime(dest, src)
+	case int:
+		return true, unixTime(dest, int64(src))
+	case int32:
+		return true, unixTime(dest, int64(src))
+	}
+	return false, nil
+}
+
+func parseTime(dest *time.Time, s string) error {
+	s = strings.TrimSpace(s)
+	for _, layout := range TimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			*dest = t
+			return nil
+		}
+	}
+	return fmt.Errorf("converting driver.Value type string (%q) to a time.Time: no matching layout in opt.TimeLayouts", s)
+}
+
+func unixTime(dest *time.Time, n int64) error {
+	switch UnixTimeUnit {
+	case time.Second:
+		*dest = time.Unix(n, 0).UTC()
+	case time.Millisecond:
+		*dest = time.UnixMilli(n).UTC()
+	case time.Microsecond:
+		*dest = time.UnixMicro(n).UTC()
+	case time.Nanosecond:
+		*dest = time.Unix(0, n).UTC()
+	case 0:
+		return fmt.Errorf("converting driver.Value type int64 (%d) to a time.Time: opt.UnixTimeUnit is not set", n)
+	default:
+		return fmt.Errorf("converting driver.Value type int64 (%d) to a time.Time: unsupported opt.UnixTimeUnit %s", n, UnixTimeUnit)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package opt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConvertAssignTimeText(t *testing.T) {
+	t.Parallel()
+
+	utc := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		src  any
+		want time.Time
+	}{
+		{"2024-01-02T03:04:05Z", utc},
+		{"2024-01-02T05:04:05.5+02:00", utc.Add(500 * time.Millisecond)},
+		{"2024-01-02 03:04:05", utc},
+		{[]byte("2024-01-02 03:04:05"), utc},
+		{"2024-01-02 03:04:05.123456", utc.Add(123456 * time.Microsecond)},
+		{"2024-01-02 03:04:05+00:00", utc},
+		{"2024-01-02T03:04:05", utc},
+		{"2024-01-02 03:04", utc.Add(-5 * time.Second)},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		var got time.Time
+		if err := ConvertAssign(&got, test.src); err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%s: want: %v got: %v", test.src, test.want, got)
+		}
+	}
+
+	var got time.Time
+	if err := ConvertAssign(&got, "yesterday"); err == nil {
+		t.Error("expected an error for text in no layout")
+	}
+}
+
+func TestConvertAssignTimeUnix(t *testing.T) {
+	// Not parallel, it changes UnixTimeUnit
+	t.Cleanup(func() { UnixTimeUnit = time.Second })
+
+	tests := []struct {
+		unit time.Duration
+		src  any
+		want time.Time
+	}{
+		{time.Second, int64(1704164645), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{time.Second, 1704164645, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{time.Millisecond, int64(1704164645123), time.Date(2024, 1, 2, 3, 4, 5, 123e6, time.UTC)},
+		{time.Microsecond, int64(1704164645123456), time.Date(2024, 1, 2, 3, 4, 5, 123456e3, time.UTC)},
+		{time.Nanosecond, int64(1704164645123456789), time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)},
+	}
+
+	for _, test := range tests {
+		UnixTimeUnit = test.unit
+		var got time.Time
+		if err := ConvertAssign(&got, test.src); err != nil {
+			t.Errorf("%s %d: %v", test.unit, test.src, err)
+			continue
+		}
+		if !got.Equal(test.want) || got.Location() != time.UTC {
+			t.Errorf("%s %d: want: %v got: %v", test.unit, test.src, test.want, got)
+		}
+	}
+
+	var got time.Time
+	UnixTimeUnit = 0
+	if err := ConvertAssign(&got, int64(1)); err == nil {
+		t.Error("expected an error when integers are disabled")
+	}
+	UnixTimeUnit = time.Hour
+	if err := ConvertAssign(&got, int64(1)); err == nil {
+		t.Error("expected an error for an unsupported unit")
+	}
+}